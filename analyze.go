@@ -0,0 +1,323 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/op/go-logging"
+)
+
+// analysisGroup is the latency distribution of a group of deliver clients or
+// transactions. Latencies are in ns.
+type analysisGroup struct {
+	Name     string
+	TX       uint64    // # of TX
+	Latency  Histogram `json:"-"`
+	Duration float64   // Longest delivery time (seconds)
+	P50      float64   // Latency percentiles (seconds)
+	P90      float64
+	P95      float64
+	P99      float64
+	Worst    float64
+}
+
+// record adds a latency of a number of TX to a group.
+func (g *analysisGroup) record(latency uint64, tx uint64, tDelivered float64) {
+	g.Latency.RecordN(latency, tx)
+	g.TX += tx
+	if tDelivered > g.Duration {
+		g.Duration = tDelivered
+	}
+}
+
+// finish computes the percentiles of a group.
+func (g *analysisGroup) finish() {
+	g.P50 = g.Latency.Seconds(.5)
+	g.P90 = g.Latency.Seconds(.9)
+	g.P95 = g.Latency.Seconds(.95)
+	g.P99 = g.Latency.Seconds(.99)
+	g.Worst = g.Latency.Seconds(1)
+}
+
+// analysisOutlier is a deliver client flagged as an outlier.
+type analysisOutlier struct {
+	Client string
+	Metric string
+	Value  float64
+	Median float64 // Median of all clients
+}
+
+// analysis is the result of obx analyze.
+type analysis struct {
+	Dir              string
+	Files            int
+	TxFiles          int // Files of all latencies
+	BlockFiles       int // Block latency files
+	All              *analysisGroup
+	DeliverServers   []*analysisGroup
+	BroadcastServers []*analysisGroup // Only from files of all latencies
+	Channels         []*analysisGroup
+	Clients          []*analysisGroup
+	Outliers         []analysisOutlier
+	OutlierThreshold float64
+	groupsByName     map[string]*analysisGroup
+}
+
+// group returns a group by name, creating it if necessary.
+func (a *analysis) group(list *[]*analysisGroup, name string) *analysisGroup {
+	g := a.groupsByName[name]
+	if g == nil {
+		g = &analysisGroup{Name: name}
+		a.groupsByName[name] = g
+		*list = append(*list, g)
+	}
+	return g
+}
+
+// The latency analyzer is called as
+//
+//	obx analyze [-prefix <prefix>] [-json] <latency directory>
+//
+// and merges the latency files in the directory, written by a run with
+// -latencyDir, to report latency percentiles for all TX and by deliver
+// server, broadcast server, channel and deliver client, flagging the outlier
+// clients. No orderer is needed, so archived results can be re-analyzed.
+func analyze() {
+
+	logger = logging.MustGetLogger("analyze")
+
+	var prefix, logLevel string
+	var asJSON bool
+	a := &analysis{groupsByName: make(map[string]*analysisGroup)}
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+
+	flags.StringVar(&prefix, "prefix", "",
+		"Only analyze the latency files with this prefix; Default all latency files")
+
+	flags.Float64Var(&a.OutlierThreshold, "outlierThreshold", 3,
+		"Flag clients more than this many (scaled) median absolute deviations from the median client; Default 3")
+
+	flags.BoolVar(&asJSON, "json", false,
+		"Set to true to print the analysis as JSON")
+
+	flags.StringVar(&logLevel, "logLevel", "info",
+		"The logging level; Default 'info'")
+
+	flags.Parse(os.Args[2:])
+
+	initLogging(logLevel)
+
+	if flags.NArg() != 1 {
+		logger.Fatalf("Usage: obx analyze [flags] <latency directory>")
+	}
+	a.Dir = flags.Arg(0)
+	if a.OutlierThreshold <= 0 {
+		bogus("outlierThreshold", "a positive number")
+	}
+
+	files, err := findLatencyFiles(a.Dir, prefix)
+	if err != nil {
+		logger.Fatalf("Error finding latency files in %s: %s", a.Dir, err)
+	}
+	if len(files) == 0 {
+		logger.Fatalf("No latency files found in %s", a.Dir)
+	}
+
+	// Merge the files. TX in block latency files are assigned the maximum
+	// latency of their block, so their percentiles are upper bounds.
+
+	a.All = &analysisGroup{Name: "All"}
+	for _, f := range files {
+		dServer := a.group(&a.DeliverServers, "Deliver Server "+strconv.Itoa(f.Server))
+		channel := a.group(&a.Channels, "Channel "+strconv.Itoa(f.Channel))
+		client := a.group(&a.Clients, fmt.Sprintf("%s.%d.%d.%d",
+			f.Prefix, f.Server, f.Channel, f.Client))
+		var isTx, isBlock bool
+		err := f.scan(
+			func(t *latencyTx) {
+				isTx = true
+				latency := uint64(t.Latency * 1e9)
+				bServer := a.group(&a.BroadcastServers,
+					"Broadcast Server "+strconv.Itoa(t.Server))
+				for _, g := range []*analysisGroup{a.All, dServer, bServer, channel, client} {
+					g.record(latency, 1, t.Tdelivered)
+				}
+			},
+			func(b *latencyBlock) {
+				isBlock = true
+				latency := uint64(b.MaxLatency * 1e9)
+				for _, g := range []*analysisGroup{a.All, dServer, channel, client} {
+					g.record(latency, b.NumTX, b.Tdelivered)
+				}
+			})
+		if err != nil {
+			logger.Fatalf("Error reading latency file: %s", err)
+		}
+		a.Files++
+		if isTx {
+			a.TxFiles++
+		}
+		if isBlock {
+			a.BlockFiles++
+		}
+	}
+
+	for _, list := range [][]*analysisGroup{
+		{a.All}, a.DeliverServers, a.BroadcastServers, a.Channels, a.Clients} {
+		for _, g := range list {
+			g.finish()
+		}
+	}
+	sort.Sort(byGroupName(a.DeliverServers))
+	sort.Sort(byGroupName(a.BroadcastServers))
+	sort.Sort(byGroupName(a.Channels))
+	a.findOutliers()
+
+	if asJSON {
+		out, err := json.MarshalIndent(a, "", "  ")
+		if err != nil {
+			logger.Fatalf("Error encoding the analysis as JSON: %s", err)
+		}
+		fmt.Printf("%s\n", out)
+	} else {
+		a.report()
+	}
+}
+
+// byGroupName sorts groups by name, which is a label followed by a number.
+type byGroupName []*analysisGroup
+
+func (b byGroupName) Len() int      { return len(b) }
+func (b byGroupName) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byGroupName) Less(i, j int) bool {
+	if len(b[i].Name) != len(b[j].Name) {
+		return len(b[i].Name) < len(b[j].Name)
+	}
+	return b[i].Name < b[j].Name
+}
+
+// findOutliers flags the clients whose median or 99th percentile latency or
+// duration is high, or whose TX count is low, compared to the other clients.
+// A value is an outlier if it is more than OutlierThreshold scaled median
+// absolute deviations from the median of all clients.
+func (a *analysis) findOutliers() {
+
+	metrics := []struct {
+		name  string
+		high  bool
+		value func(g *analysisGroup) float64
+	}{
+		{"Median Latency", true, func(g *analysisGroup) float64 { return g.P50 }},
+		{"99% Latency", true, func(g *analysisGroup) float64 { return g.P99 }},
+		{"Duration", true, func(g *analysisGroup) float64 { return g.Duration }},
+		{"TX", false, func(g *analysisGroup) float64 { return float64(g.TX) }},
+	}
+
+	if len(a.Clients) < 3 {
+		return // Too few to tell
+	}
+	values := make([]float64, len(a.Clients))
+	for _, m := range metrics {
+		for i, g := range a.Clients {
+			values[i] = m.value(g)
+		}
+		median, mad := medianAbsoluteDeviation(values)
+		limit := a.OutlierThreshold * 1.4826 * mad
+		if limit == 0 {
+			limit = 0.05 * median // All clients agree; Allow 5%
+		}
+		for i, g := range a.Clients {
+			deviation := values[i] - median
+			if !m.high {
+				deviation = -deviation
+			}
+			if deviation > limit {
+				a.Outliers = append(a.Outliers,
+					analysisOutlier{g.Name, m.name, values[i], median})
+			}
+		}
+	}
+}
+
+// medianAbsoluteDeviation returns the median of a set of values, and the
+// median of their absolute deviations from it.
+func medianAbsoluteDeviation(values []float64) (median, mad float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	median = sorted[len(sorted)/2]
+	for i, v := range values {
+		sorted[i] = math.Abs(v - median)
+	}
+	sort.Float64s(sorted)
+	mad = sorted[len(sorted)/2]
+	return
+}
+
+// report prints an analysis as text.
+func (a *analysis) report() {
+
+	fmt.Printf("****************************************************************************\n")
+	fmt.Printf("Latency Analysis: %s\n", a.Dir)
+	fmt.Printf("    Latency Files          : %d (%d of all TX, %d of blocks)\n",
+		a.Files, a.TxFiles, a.BlockFiles)
+	fmt.Printf("    TX Delivered           : %s\n", commafy(int64(a.All.TX)))
+	if a.BlockFiles != 0 {
+		fmt.Printf("    Note                   : TX in block files have the maximum latency of their block\n")
+	}
+
+	for _, section := range []struct {
+		title  string
+		groups []*analysisGroup
+	}{
+		{"All", []*analysisGroup{a.All}},
+		{"By Deliver Server", a.DeliverServers},
+		{"By Broadcast Server", a.BroadcastServers},
+		{"By Channel", a.Channels},
+		{"By Deliver Client", a.Clients},
+	} {
+		if len(section.groups) == 0 {
+			continue
+		}
+		fmt.Printf("****************************************************************************\n")
+		fmt.Printf("%-28s %10s %9s %9s %9s %9s %9s %9s\n", "Latency "+section.title,
+			"TX", "Median", "90%", "95%", "99%", "Worst", "Duration")
+		for _, g := range section.groups {
+			fmt.Printf("    %-24s %10s %9.6f %9.6f %9.6f %9.6f %9.6f %9.3f\n",
+				g.Name, commafy(int64(g.TX)), g.P50, g.P90, g.P95, g.P99,
+				g.Worst, g.Duration)
+		}
+	}
+
+	fmt.Printf("****************************************************************************\n")
+	if len(a.Outliers) == 0 {
+		fmt.Printf("Outlier Clients: None\n")
+	} else {
+		fmt.Printf("Outlier Clients\n")
+		for _, o := range a.Outliers {
+			fmt.Printf("    %-24s %-14s: %.6g vs. median %.6g\n",
+				o.Client, o.Metric, o.Value, o.Median)
+		}
+	}
+	fmt.Printf("****************************************************************************\n")
+}
@@ -0,0 +1,100 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestAnalysisGroupRecord(t *testing.T) {
+	tests := []struct {
+		latency, tx uint64
+		tDelivered  float64
+	}{
+		{1000000, 1, 1.5},
+		{2000000, 10, 0.5},
+		{5000000, 0, 9}, // An empty block still extends the duration
+		{3000000, 89, 2},
+	}
+	var g analysisGroup
+	var want Histogram
+	for _, tt := range tests {
+		g.record(tt.latency, tt.tx, tt.tDelivered)
+		for i := uint64(0); i < tt.tx; i++ {
+			want.Record(tt.latency)
+		}
+	}
+	g.finish()
+	if (g.TX != 100) || (g.Duration != 9) || !equalHistograms(&g.Latency, &want) {
+		t.Errorf("TX %d, Duration %v, Latency %+v; want 100, 9, %+v",
+			g.TX, g.Duration, g.Latency, want)
+	}
+	if (g.P50 != want.Seconds(.5)) || (g.P99 != want.Seconds(.99)) || (g.Worst != 0.003) {
+		t.Errorf("P50 %v, P99 %v, Worst %v", g.P50, g.P99, g.Worst)
+	}
+}
+
+func TestMedianAbsoluteDeviation(t *testing.T) {
+	tests := []struct {
+		values      []float64
+		median, mad float64
+	}{
+		{[]float64{5}, 5, 0},
+		{[]float64{1, 2, 3}, 2, 1},
+		{[]float64{3, 1, 2, 100, 2}, 2, 1},
+		{[]float64{7, 7, 7, 7}, 7, 0},
+	}
+	for _, tt := range tests {
+		values := append([]float64(nil), tt.values...)
+		median, mad := medianAbsoluteDeviation(values)
+		if (median != tt.median) || (mad != tt.mad) {
+			t.Errorf("medianAbsoluteDeviation(%v) = %v, %v; want %v, %v",
+				tt.values, median, mad, tt.median, tt.mad)
+		}
+		if !reflect.DeepEqual(values, tt.values) {
+			t.Errorf("medianAbsoluteDeviation(%v) modified its argument: %v", tt.values, values)
+		}
+	}
+}
+
+func TestFindOutliers(t *testing.T) {
+	a := &analysis{OutlierThreshold: 3, groupsByName: make(map[string]*analysisGroup)}
+	for i := 0; i < 5; i++ {
+		g := a.group(&a.Clients, "Client "+strconv.Itoa(i))
+		latency := uint64(10000000)
+		tx := uint64(1000)
+		if i == 3 {
+			latency *= 10
+		}
+		if i == 4 {
+			tx /= 2
+		}
+		g.record(latency, tx, 10)
+		g.finish()
+	}
+	a.findOutliers()
+	want := []analysisOutlier{
+		{"Client 3", "Median Latency", a.Clients[3].P50, a.Clients[0].P50},
+		{"Client 3", "99% Latency", a.Clients[3].P99, a.Clients[0].P99},
+		{"Client 4", "TX", 500, 1000},
+	}
+	if !reflect.DeepEqual(a.Outliers, want) {
+		t.Errorf("Outliers = %+v, want %+v", a.Outliers, want)
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+// blockRecord is a block holding obx transactions delivered to a deliver
+// client. Block sizes are measured as the orderer's block cutter measures
+// them, as the sum of the payload and signature sizes of the envelopes.
+type blockRecord struct {
+	tx        uint64 // # of TX in the block
+	bytes     uint64 // Size of the TX in the block
+	gap       uint64 // Time since the previous block was delivered, or 0
+	formation uint64 // Time from the earliest broadcast to delivery
+}
+
+// BlockStats aggregates the blocks holding obx transactions delivered to
+// deliver clients, and classifies how they were cut. Times are in ns. Blocks
+// are added one at a time as they are delivered, and classified once all of
+// them have been added. Until then, the blocks with the largest TX count seen
+// and the others are held in separate bands. The classification state is not
+// sent to the control process.
+type BlockStats struct {
+	Blocks       uint64    // # of blocks
+	CutByCount   uint64    // # of blocks cut at the batch size
+	CutByBytes   uint64    // # of blocks cut at the byte limit
+	CutByTimeout uint64    // # of blocks cut by the batch timeout
+	MaxMessage   uint64    // Size of the largest TX seen
+	Tx           Histogram // TX per block
+	Bytes        Histogram // Bytes per block
+	Gaps         Histogram // Inter-block arrival times
+	BytesCut     Histogram // Bytes per block cut at the byte limit
+	Timeouts     Histogram // Formation latency of blocks cut by timeout
+
+	maxTx uint64    // Largest TX count seen
+	full  blockBand // Blocks with the largest TX count seen
+	other blockBand // The other blocks
+}
+
+// blockBand holds the formation latencies of a set of blocks by the histogram
+// bucket of their size, so that the blocks can be classified by size once
+// all of them have been seen, in memory that does not grow with the # of
+// blocks.
+type blockBand struct {
+	n        uint64             // # of blocks
+	maxBytes uint64             // Size of the largest block
+	sizes    map[int]*Histogram // Formation latencies by size bucket
+}
+
+// add adds a block to the band.
+func (b *blockBand) add(r *blockRecord) {
+	if b.sizes == nil {
+		b.sizes = make(map[int]*Histogram)
+	}
+	bucket := histogramBucket(r.bytes)
+	h := b.sizes[bucket]
+	if h == nil {
+		h = &Histogram{}
+		b.sizes[bucket] = h
+	}
+	h.Record(r.formation)
+	b.n++
+	if r.bytes > b.maxBytes {
+		b.maxBytes = r.bytes
+	}
+}
+
+// merge moves the blocks of another band to this one.
+func (b *blockBand) merge(o *blockBand) {
+	if o.n == 0 {
+		return
+	}
+	if b.sizes == nil {
+		b.sizes = make(map[int]*Histogram)
+	}
+	for bucket, h := range o.sizes {
+		if b.sizes[bucket] == nil {
+			b.sizes[bucket] = &Histogram{}
+		}
+		b.sizes[bucket].Merge(h)
+	}
+	b.n += o.n
+	if o.maxBytes > b.maxBytes {
+		b.maxBytes = o.maxBytes
+	}
+	*o = blockBand{}
+}
+
+// add records a block delivered to a client.
+func (s *BlockStats) add(r *blockRecord) {
+	s.Blocks++
+	s.Tx.Record(r.tx)
+	s.Bytes.Record(r.bytes)
+	if r.gap != 0 {
+		s.Gaps.Record(r.gap)
+	}
+	switch {
+	case r.tx > s.maxTx:
+		s.other.merge(&s.full)
+		s.maxTx = r.tx
+		s.full.add(r)
+	case r.tx == s.maxTx:
+		s.full.add(r)
+	default:
+		s.other.add(r)
+	}
+}
+
+// finish classifies the blocks added, given the size of the largest message
+// seen. The batch size is taken to be the largest TX count seen, provided at
+// least two blocks have that count. Among the other blocks, those within one
+// message of the largest size seen are taken to be cut at the byte limit,
+// provided there are at least two of them. The rest were cut by the batch
+// timeout. Sizes are compared to within the resolution of the histogram
+// buckets.
+func (s *BlockStats) finish(maxMessage uint64) {
+
+	if s.full.n >= 2 {
+		s.CutByCount += s.full.n
+		s.full = blockBand{}
+	} else {
+		s.other.merge(&s.full)
+	}
+
+	maxBytes := s.other.maxBytes
+	nearest := 0
+	if maxBytes >= maxMessage {
+		nearest = histogramBucket(maxBytes - maxMessage + 1)
+	}
+	var near uint64
+	for bucket, h := range s.other.sizes {
+		if bucket >= nearest {
+			near += h.N
+		}
+	}
+	byteLimited := near >= 2
+
+	for bucket, h := range s.other.sizes {
+		if byteLimited && (bucket >= nearest) {
+			size := histogramValue(bucket)
+			if (size > maxBytes) || (bucket == histogramBucket(maxBytes)) {
+				size = maxBytes
+			}
+			s.CutByBytes += h.N
+			s.BytesCut.RecordN(size, h.N)
+		} else {
+			s.CutByTimeout += h.N
+			s.Timeouts.Merge(h)
+		}
+	}
+	s.other = blockBand{}
+	if maxMessage > s.MaxMessage {
+		s.MaxMessage = maxMessage
+	}
+}
+
+// merge adds the block statistics of another client.
+func (s *BlockStats) merge(o *BlockStats) {
+	s.Blocks += o.Blocks
+	s.CutByCount += o.CutByCount
+	s.CutByBytes += o.CutByBytes
+	s.CutByTimeout += o.CutByTimeout
+	if o.MaxMessage > s.MaxMessage {
+		s.MaxMessage = o.MaxMessage
+	}
+	s.Tx.Merge(&o.Tx)
+	s.Bytes.Merge(&o.Bytes)
+	s.Gaps.Merge(&o.Gaps)
+	s.BytesCut.Merge(&o.BytesCut)
+	s.Timeouts.Merge(&o.Timeouts)
+}
+
+// report prints the block statistics, including the effective block cutting
+// parameters inferred from them. The BatchSize is exact if any blocks were
+// cut at the batch size. AbsoluteMaxBytes is bracketed by the sizes of the
+// blocks cut at the byte limit, and the BatchTimeout is estimated as the
+// median formation latency of the blocks cut by timeout, which also includes
+// the delivery latency.
+func (s *BlockStats) report() {
+
+	percent := func(n uint64) float64 {
+		return 100 * float64(n) / float64(s.Blocks)
+	}
+
+	fmt.Printf("****************************************************************************\n")
+	fmt.Printf("Block Statistics (All Deliver Clients)\n")
+	fmt.Printf("    Blocks Delivered       : %s\n", commafy(int64(s.Blocks)))
+	fmt.Printf("    Cut by Size            : %s (%.1f%%); %s by TX count, %s by bytes\n",
+		commafy(int64(s.CutByCount+s.CutByBytes)),
+		percent(s.CutByCount+s.CutByBytes),
+		commafy(int64(s.CutByCount)), commafy(int64(s.CutByBytes)))
+	fmt.Printf("    Cut by Timeout         : %s (%.1f%%)\n",
+		commafy(int64(s.CutByTimeout)), percent(s.CutByTimeout))
+
+	if s.CutByCount != 0 {
+		fmt.Printf("    BatchSize (Inferred)   : %s TX\n", commafy(int64(s.Tx.Max)))
+	} else {
+		fmt.Printf("    BatchSize (Inferred)   : Not reached; Largest block %s TX\n",
+			commafy(int64(s.Tx.Max)))
+	}
+	if s.CutByBytes != 0 {
+		fmt.Printf("    AbsoluteMaxBytes (Inf.): %s - %s bytes\n",
+			commafy(int64(s.BytesCut.Max)),
+			commafy(int64(s.BytesCut.Min+s.MaxMessage)))
+	} else {
+		fmt.Printf("    AbsoluteMaxBytes (Inf.): Not reached; Largest block %s bytes\n",
+			commafy(int64(s.Bytes.Max)))
+	}
+	if s.CutByTimeout != 0 {
+		fmt.Printf("    BatchTimeout (Inferred): ~%.3f seconds\n", s.Timeouts.Seconds(.5))
+	} else {
+		fmt.Printf("    BatchTimeout (Inferred): Not reached\n")
+	}
+
+	fmt.Printf("Blocks             :       Best     Median        90%%        95%%        99%%      Worst\n")
+	fmt.Printf("    Tx Per Block   : %10s %10s %10s %10s %10s %10s\n",
+		commafy(int64(s.Tx.Quantile(0))), commafy(int64(s.Tx.Quantile(.5))),
+		commafy(int64(s.Tx.Quantile(.9))), commafy(int64(s.Tx.Quantile(.95))),
+		commafy(int64(s.Tx.Quantile(.99))), commafy(int64(s.Tx.Quantile(1))))
+	fmt.Printf("    Bytes Per Block: %10s %10s %10s %10s %10s %10s\n",
+		commafy(int64(s.Bytes.Quantile(0))), commafy(int64(s.Bytes.Quantile(.5))),
+		commafy(int64(s.Bytes.Quantile(.9))), commafy(int64(s.Bytes.Quantile(.95))),
+		commafy(int64(s.Bytes.Quantile(.99))), commafy(int64(s.Bytes.Quantile(1))))
+	fmt.Printf("    Gap Seconds    : %10.6f %10.6f %10.6f %10.6f %10.6f %10.6f\n",
+		s.Gaps.Seconds(0), s.Gaps.Seconds(.5), s.Gaps.Seconds(.9),
+		s.Gaps.Seconds(.95), s.Gaps.Seconds(.99), s.Gaps.Seconds(1))
+}
@@ -0,0 +1,132 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestBlockStatsClassification(t *testing.T) {
+	type block struct {
+		tx, bytes uint64
+	}
+	tests := []struct {
+		name       string
+		blocks     []block
+		maxMessage uint64
+		count      uint64
+		bytes      uint64
+		timeout    uint64
+		bytesCut   uint64 // Largest block cut at the byte limit
+	}{
+		{
+			name:       "single block",
+			blocks:     []block{{5, 500}},
+			maxMessage: 100,
+			timeout:    1,
+		},
+		{
+			name: "batch size",
+			blocks: []block{
+				{10, 1000}, {10, 1000}, {10, 1000}, {3, 300},
+			},
+			maxMessage: 100,
+			count:      3,
+			timeout:    1,
+		},
+		{
+			name: "byte limit",
+			blocks: []block{
+				{6, 1000}, {5, 950}, {4, 990}, {2, 300},
+			},
+			maxMessage: 100,
+			bytes:      3,
+			timeout:    1,
+			bytesCut:   1000,
+		},
+		{
+			name: "batch size and byte limit",
+			blocks: []block{
+				{8, 800}, {3, 990}, {8, 800}, {2, 1000}, {1, 20},
+			},
+			maxMessage: 500,
+			count:      2,
+			bytes:      2,
+			timeout:    1,
+			bytesCut:   1000,
+		},
+		{
+			name: "larger batch seen later",
+			blocks: []block{
+				{3, 30}, {3, 90}, {5, 500}, {5, 500},
+			},
+			maxMessage: 10,
+			count:      2,
+			timeout:    2,
+		},
+	}
+	for _, tt := range tests {
+		var s BlockStats
+		for i, b := range tt.blocks {
+			s.add(&blockRecord{tx: b.tx, bytes: b.bytes, gap: uint64(i), formation: 1000})
+		}
+		s.finish(tt.maxMessage)
+		if (s.Blocks != uint64(len(tt.blocks))) || (s.CutByCount != tt.count) ||
+			(s.CutByBytes != tt.bytes) || (s.CutByTimeout != tt.timeout) {
+			t.Errorf("%s: %d blocks, %d by count, %d by bytes, %d by timeout; "+
+				"want %d, %d, %d, %d", tt.name,
+				s.Blocks, s.CutByCount, s.CutByBytes, s.CutByTimeout,
+				len(tt.blocks), tt.count, tt.bytes, tt.timeout)
+		}
+		if (s.BytesCut.N != tt.bytes) || (s.BytesCut.Max != tt.bytesCut) {
+			t.Errorf("%s: BytesCut N %d, Max %d; want %d, %d", tt.name,
+				s.BytesCut.N, s.BytesCut.Max, tt.bytes, tt.bytesCut)
+		}
+		if s.Timeouts.N != tt.timeout {
+			t.Errorf("%s: %d timeout latencies, want %d", tt.name, s.Timeouts.N, tt.timeout)
+		}
+		if s.Gaps.N != uint64(len(tt.blocks)-1) {
+			t.Errorf("%s: %d gaps, want %d", tt.name, s.Gaps.N, len(tt.blocks)-1)
+		}
+		if s.MaxMessage != tt.maxMessage {
+			t.Errorf("%s: MaxMessage %d, want %d", tt.name, s.MaxMessage, tt.maxMessage)
+		}
+	}
+}
+
+func TestBlockStatsMerge(t *testing.T) {
+	var a, b, all BlockStats
+	for i := uint64(1); i <= 10; i++ {
+		r := blockRecord{tx: i, bytes: 100 * i, gap: i, formation: i}
+		if i%2 == 0 {
+			a.add(&r)
+		} else {
+			b.add(&r)
+		}
+		all.add(&r)
+	}
+	a.finish(100)
+	b.finish(100)
+	all.finish(100)
+	a.merge(&b)
+	if (a.Blocks != all.Blocks) ||
+		(a.CutByCount+a.CutByBytes+a.CutByTimeout != a.Blocks) ||
+		!equalHistograms(&a.Tx, &all.Tx) || !equalHistograms(&a.Bytes, &all.Bytes) ||
+		!equalHistograms(&a.Gaps, &all.Gaps) {
+		t.Errorf("merged %+v, want the totals of %+v", a, all)
+	}
+}
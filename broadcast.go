@@ -40,6 +40,7 @@ import (
 func broadcast() {
 
 	logger = logging.MustGetLogger("broadcast")
+	monitor := newUsageMonitor()
 
 	// Parse args
 
@@ -55,7 +56,9 @@ func broadcast() {
 	}
 
 	// Connect back to the control process for RPC, get the full
-	// configuration and start time, then initialize logging.
+	// configuration and start time, then initialize logging. The clock
+	// handshake estimates the control clock, from which the client takes its
+	// timestamps.
 
 	rpcClient, err := rpc.DialHTTP("tcp", control)
 	if err != nil {
@@ -73,33 +76,93 @@ func broadcast() {
 	if err != nil {
 		logger.Fatalf("RPC call for Control.Tstart failed: %s", err)
 	}
+	clock := newClock(Tstart, syncClock(&client, rpcClient))
 
 	initLogging(cfg.BroadcastLogging)
 	logger.Debugf("Broadcast client %v: Configuration %v\n", client, cfg)
 
-	// Open the gRPC connection to the orderer
+	// Open the gRPC connections to the orderer, and the broadcast streams on
+	// each connection.
 
-	connection, err :=
-		grpc.Dial(cfg.Bservers[server], grpc.WithInsecure())
-	if err != nil {
-		client.fail(rpcClient,
-			"Broadcast client %v did not connect to %s: %s\n",
-			client, cfg.Bservers[server], err)
+	var streams []orderer.AtomicBroadcast_BroadcastClient
+	for i := 0; i < cfg.ConnsPerClient; i++ {
+		connection, err :=
+			grpc.Dial(cfg.Bservers[server], dialOptions(&cfg)...)
+		if err != nil {
+			client.fail(rpcClient,
+				"Broadcast client %v did not connect to %s: %s\n",
+				client, cfg.Bservers[server], err)
+		}
+		iface := orderer.NewAtomicBroadcastClient(connection)
+		for j := 0; j < cfg.StreamsPerConn; j++ {
+			stream, err := iface.Broadcast(context.Background())
+			if err != nil {
+				client.fail(rpcClient,
+					"Broadcast client %v to server %s; Failed to invoke broadcast RPC: %s",
+					client, cfg.Bservers[server], err)
+			}
+			streams = append(streams, stream)
+		}
+	}
+
+	// Broadcast, then estimate the clock drift, signal Done, and we're oot.
+
+	workload := cfg.workload(server, channel)
+	done := &BroadcastClient{Client: client, Series: newTimeSeries(&cfg)}
+	progress := broadcastProgress(&cfg, &client, rpcClient, workload)
+	monitor.begin()
+	broadcastStreams(&cfg, &client, workload, streams, clock, done, progress,
+		func(format string, args ...interface{}) {
+			client.fail(rpcClient, format, args...)
+		})
+	monitor.end()
+	if progress != nil {
+		progress(workload.Transactions)
 	}
-	iface := orderer.NewAtomicBroadcastClient(connection)
-	stream, err := iface.Broadcast(context.Background())
+
+	clock.finish(syncClock(&client, rpcClient))
+	done.Clock = *clock
+	done.Usage = monitor.usage()
+
+	var ignore int
+	err = rpcClient.Call("Control.BroadcastDone", done, &ignore)
 	if err != nil {
-		client.fail(rpcClient,
-			"Broadcast client %v to server %s; Failed to invoke broadcast RPC: %s",
-			client, cfg.Bservers[server], err)
+		logger.Fatalf(
+			"Broadcast client %v: RPC Control.BroadcastDone failed: %s",
+			client, err)
 	}
 
-	// Start the ACK thread
+	for _, stream := range streams {
+		stream.CloseSend()
+	}
+}
 
-	done := make(chan int)
-	go broadcastReplies(&client, stream, cfg.Transactions, done, rpcClient)
+// broadcastStreams broadcasts the workload of a client to a set of streams,
+// and waits for all the ACKs, recording the results in done. If progress is
+// not nil, it is called with the # of TX sent after each TX. fail is called
+// to signal a fatal error. This is shared by broadcast clients and the
+// self-test.
+func broadcastStreams(cfg *Config, client *Client, workload *Workload,
+	streams []orderer.AtomicBroadcast_BroadcastClient, clock *Clock,
+	done *BroadcastClient, progress func(sent int),
+	fail func(format string, args ...interface{})) {
+
+	// Start an ACK thread for each stream. TX are sent to the streams
+	// round-robin, so each stream expects every n'th ACK. The ACK threads
+	// share the in-flight window, but record their own time series.
+
+	acked := make(chan int)
+	window := newInFlightWindow(cfg.MaxInFlight)
+	acks := make([]*TimeSeries, len(streams))
+	for i, stream := range streams {
+		acks[i] = newTimeSeries(cfg)
+		n := (workload.Transactions - i + len(streams) - 1) / len(streams)
+		go broadcastReplies(client, i, stream, n, acked,
+			clock, acks[i], window, fail)
+	}
 
-	// Do the broadcast
+	// Do the broadcast. Payloads are built in place from a template unless
+	// the template does not match the protobuf library.
 
 	envelope := &common.Envelope{}
 	header :=
@@ -109,87 +172,121 @@ func broadcast() {
 			},
 			SignatureHeader: &common.SignatureHeader{},
 		}
-	data := make([]byte, cfg.Payload)
-	payload := &common.Payload{Header: header, Data: data}
+	sizer := newPayloadSizer(&workload.PayloadDist, client)
+	pool := newPayloadPool(&cfg.Content, workload.PayloadDist.Max, client.seed())
+	data := make([]byte, workload.PayloadDist.Max)
+	payload := &common.Payload{Header: header}
+	template := newPayloadTemplate(header, workload.PayloadDist.Max)
+	if template == nil {
+		logger.Warningf("Broadcast client %v: The payload template does not "+
+			"match the protobuf encoding; Payloads will be marshaled per TX", client)
+	}
 
 	txHeader := TxHeader{
-		Server:  uint16(server),
-		Channel: uint16(channel),
-		Client:  uint16(clientIndex),
+		Server:  uint16(client.Server),
+		Channel: uint16(client.Channel),
+		Client:  uint16(client.Client),
 	}
 
-	for tx := 0; tx < cfg.Transactions; {
-		for i := 0; i < cfg.Burst; i++ {
+	phases := newPhaseTracker(
+		cfg, uint64(workload.Transactions), false, &done.Phases)
+	var timestamp uint64
+	var err error
+
+	for tx := 0; tx < workload.Transactions; {
+		for i := 0; i < workload.Burst; i++ {
 
 			logger.Debugf("Broadcast client %v: Send Tx %d", client, tx)
 
-			timestamp := uint64(time.Since(Tstart))
+			if window.reserve() {
+				done.Blocked++
+			}
+			timestamp = clock.since()
 
+			size := sizer.next()
 			txHeader.Sequence = uint32(tx)
 			txHeader.Tbroadcast = timestamp
-			txHeader.Put(data)
 
-			payloadBytes, err := proto.Marshal(payload)
-			if err != nil {
-				client.fail(rpcClient,
-					"Broadcast client %v: Payload marshaling failed: %s",
-					client, err)
+			if template != nil {
+				envelope.Payload, payload.Data = template.marshal(size)
+				txHeader.Put(payload.Data)
+				pool.fill(payload.Data[TxHeaderSize:])
+			} else {
+				payload.Data = data[:size]
+				txHeader.Put(payload.Data)
+				pool.fill(payload.Data[TxHeaderSize:])
+				envelope.Payload, err = proto.Marshal(payload)
+				if err != nil {
+					fail("Broadcast client %v: Payload marshaling failed: %s",
+						client, err)
+				}
 			}
-			envelope.Payload = payloadBytes
+			done.Generation += clock.since() - timestamp
 
-			err = stream.Send(envelope)
+			err = streams[tx%len(streams)].Send(envelope)
 			if err != nil {
-				client.fail(rpcClient,
-					"Broadcast client %v: Send() error: %s",
+				fail("Broadcast client %v: Send() error: %s",
 					client, err)
 			}
+			done.InFlight.Record(window.send())
+			phases.add(txHeader.Sequence, timestamp, timestamp,
+				uint64(size), 0)
+			done.Bytes += uint64(size)
+			done.Sizes.Record(uint64(size))
+			done.Series.broadcast(timestamp, uint64(size))
 
 			tx++
-			if tx == cfg.Transactions {
+			if progress != nil {
+				progress(tx)
+			}
+			if tx == workload.Transactions {
 				break
 			}
 		}
 
-		if (tx < cfg.Transactions) && (cfg.Delay != 0) {
-			time.Sleep(cfg.Delay)
+		if tx < workload.Transactions {
+			delay := cfg.burstDelay(workload, txHeader.Sequence, timestamp)
+			if delay != 0 {
+				time.Sleep(delay)
+			}
 		}
 	}
+	phases.finish()
 
-	// Wait for the ACK thread, signal Done, and we're oot.
+	// Wait for the ACK threads before merging their time series.
 
-	<-done
-
-	var ignore int
-	err = rpcClient.Call("Control.BroadcastDone", client, &ignore)
-	if err != nil {
-		logger.Fatalf(
-			"Broadcast client %v: RPC Control.BroadcastDone failed: %s",
-			client, err)
+	for range streams {
+		<-acked
+	}
+	if done.Series != nil {
+		for _, series := range acks {
+			done.Series.merge(series)
+		}
 	}
-
-	stream.CloseSend()
 }
 
-// broadcastReplies handles the broadcast ACKs
+// broadcastReplies handles the broadcast ACKs of a stream, opening the
+// in-flight window and recording them in the time series if requested.
 func broadcastReplies(
-	client *Client, stream orderer.AtomicBroadcast_BroadcastClient,
-	tx int, done chan int, rpcClient *rpc.Client) {
+	client *Client, index int, stream orderer.AtomicBroadcast_BroadcastClient,
+	tx int, done chan int, clock *Clock, acks *TimeSeries,
+	window *inFlightWindow, fail func(format string, args ...interface{})) {
 
 	for count := 0; count < tx; count++ {
 
 		reply, err := stream.Recv()
 		if err != nil {
-			client.fail(rpcClient,
-				"Ack client %v: Reply error at count %d: %s",
-				client, count, err)
+			fail("Ack client %v stream %d: Reply error at count %d: %s",
+				client, index, count, err)
 		}
 		if reply.Status != common.Status_SUCCESS {
-			client.fail(rpcClient,
-				"Ack client %v: Unsuccessful response at count %d: %s",
-				client, count, reply.Status.String())
+			fail("Ack client %v stream %d: Unsuccessful response at count %d: %s",
+				client, index, count, reply.Status.String())
 		}
-		logger.Debugf("Ack client %v: Reply from orderer at count %d: %s",
-			client, count, reply.Status.String())
+		logger.Debugf("Ack client %v stream %d: Reply from orderer at count %d: %s",
+			client, index, count, reply.Status.String())
+		window.ack()
+		acks.ack(clock.since())
 	}
 
 	done <- 0
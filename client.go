@@ -20,6 +20,8 @@ import (
 	"fmt"
 	"net/rpc"
 	"os"
+
+	"google.golang.org/grpc"
 )
 
 const (
@@ -35,14 +37,57 @@ type Client struct {
 	Client  int
 }
 
+// BroadcastClient represents the final status of a broadcast client,
+// including the payload bytes and the distribution of payload sizes it
+// broadcast, the statistics of its transactions in each phase, its estimate
+// of the control clock and the resource usage of the process. InFlight is the
+// distribution of the # of TX in flight as each TX was sent, and Blocked the
+// # of sends that waited for the in-flight window to open. Generation is the
+// time spent generating TX (ns). The time series is only recorded if
+// requested.
+type BroadcastClient struct {
+	Client
+	Bytes      uint64
+	Sizes      Histogram
+	Phases     [NumPhases]PhaseStats
+	Series     *TimeSeries
+	Clock      Clock
+	Usage      Usage
+	InFlight   Histogram
+	Blocked    uint64
+	Generation uint64
+}
+
 // DeliverClient represents the final status of a deliver client. It includes the
 // elapsed time (in float64-seconds), as well as the number of missing TX and
-// TX delivered on the wrong channel - both of which should be 0.
+// TX delivered on the wrong channel - both of which should be 0. The phase
+// statistics include the delivery latencies of the transactions, and
+// LastBlock is the number of the last block delivered. Bytes and Sizes
+// account for the payloads delivered, and Blocks for the blocks. Clock is the
+// client's estimate of the control clock, and Usage the resource usage of the
+// process. The time series is only recorded if requested, and the read
+// statistics only in read benchmarks. The latencies of a late joiner are
+// recorded separately, and if there are late joiners, the clients that are
+// not split their latencies by whether the TX were broadcast while any late
+// joiner was catching up.
 type DeliverClient struct {
 	Client
 	Elapsed      float64
 	Missing      uint64
 	WrongChannel uint64
+	Bytes        uint64
+	Sizes        Histogram
+	Phases       [NumPhases]PhaseStats
+	LastBlock    uint64
+	Blocks       BlockStats
+	Series       *TimeSeries
+	Clock        Clock
+	Usage        Usage
+	Reads        ReadStats
+
+	JoinerLatency  Histogram
+	DuringCatchUp  Histogram
+	OutsideCatchUp Histogram
 }
 
 // ClientFailed is used in the Fail callback to signal failure
@@ -51,6 +96,24 @@ type ClientFailed struct {
 	err error
 }
 
+// seed returns a seed for random number generators that is unique to the
+// client, so that each run with the same configuration is repeatable.
+func (c *Client) seed() int64 {
+	return ((int64(c.Server) << 32) | (int64(c.Channel) << 16) |
+		int64(c.Client)) + 1
+}
+
+// dialOptions returns the gRPC dial options for connections to the orderer.
+func dialOptions(cfg *Config) []grpc.DialOption {
+	options := []grpc.DialOption{grpc.WithInsecure()}
+	if cfg.Compression == GzipCompression {
+		options = append(options,
+			grpc.WithCompressor(grpc.NewGZIPCompressor()),
+			grpc.WithDecompressor(grpc.NewGZIPDecompressor()))
+	}
+	return options
+}
+
 // fail signals client failure back to the controller, and fails the process.
 func (c *Client) fail(rpc *rpc.Client, format string, args ...interface{}) {
 	cf := &ClientFailed{
@@ -0,0 +1,168 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"math"
+	"net/rpc"
+	"time"
+)
+
+// Client timestamps are relative to the start time of the control process,
+// but clients may run on other hosts whose clocks are not synchronized with
+// the control process, and the start time reaches clients without its
+// monotonic clock reading. Each client therefore estimates the offset of the
+// control clock from its own with an NTP-style handshake before the run, and
+// takes its timestamps from its own monotonic clock relative to the local
+// time of the control start time. A second handshake after the run estimates
+// the drift of the clocks, which is used to correct the timestamps of the
+// client once the run is over.
+
+// clockExchanges is the number of exchanges of a handshake. The exchange
+// with the shortest round trip gives the estimate.
+const clockExchanges = 8
+
+// clockSample is the result of a handshake: the local time halfway through
+// the exchange, the control time in ns since the epoch, and the round-trip
+// time of the exchange.
+type clockSample struct {
+	local   time.Time
+	control int64
+	rtt     time.Duration
+}
+
+// Clock is a client's estimate of the control clock. The offset is the
+// control clock minus the client clock at the start of the run, and the drift
+// is the rate of the control clock relative to the client clock, minus 1.
+// Timestamps corrected with the estimate are within Error of the control
+// clock.
+type Clock struct {
+	Offset int64   // ns
+	Drift  float64 // ns/ns
+	Error  uint64  // ns
+	start  time.Time
+	tStart time.Time
+}
+
+// syncClock makes a clock handshake with the control process.
+func syncClock(client *Client, rpcClient *rpc.Client) (best clockSample) {
+	best.rtt = time.Duration(math.MaxInt64)
+	for i := 0; i < clockExchanges; i++ {
+		var control int64
+		t0 := time.Now()
+		err := rpcClient.Call("Control.Clock", client, &control)
+		rtt := time.Since(t0)
+		if err != nil {
+			client.fail(rpcClient, "Client %v: RPC Control.Clock failed: %s",
+				client, err)
+		}
+		if rtt < best.rtt {
+			best = clockSample{t0.Add(rtt / 2), control, rtt}
+		}
+	}
+	return
+}
+
+// newClock creates a Clock from the control start time and a handshake made
+// before the run.
+func newClock(tStart time.Time, s clockSample) *Clock {
+	return &Clock{
+		Offset: s.control - s.local.UnixNano(),
+		Error:  uint64(s.rtt / 2),
+		start:  s.local.Add(time.Duration(tStart.UnixNano() - s.control)),
+		tStart: tStart,
+	}
+}
+
+// localClock creates a Clock for a client running in the control process,
+// which needs no handshake.
+func localClock() *Clock {
+	now := time.Now()
+	return &Clock{start: now, tStart: now}
+}
+
+// since returns the time since the control start time (ns).
+func (c *Clock) since() uint64 {
+	t := time.Since(c.start)
+	if t < 0 {
+		return 0
+	}
+	return uint64(t)
+}
+
+// finish estimates the drift from a handshake made after the run. The error
+// of a corrected timestamp is bounded by the larger error of the two
+// handshakes.
+func (c *Clock) finish(s clockSample) {
+	local := s.local.Sub(c.start)
+	control := time.Duration(s.control - c.tStart.UnixNano())
+	if local > 0 {
+		c.Drift = float64(control-local) / float64(local)
+	}
+	if uint64(s.rtt/2) > c.Error {
+		c.Error = uint64(s.rtt / 2)
+	}
+}
+
+// correct corrects a timestamp of the client for the drift of its clock.
+func (c *Clock) correct(t uint64) uint64 {
+	corrected := int64(t) + int64(c.Drift*float64(t))
+	if corrected < 0 {
+		return 0
+	}
+	return uint64(corrected)
+}
+
+// clockStats summarizes the clock estimates of a set of clients.
+type clockStats struct {
+	maxOffset int64   // Largest absolute offset (ns)
+	maxDrift  float64 // Largest absolute drift
+	maxError  uint64  // Largest error bound (ns)
+}
+
+// summarizeClocks summarizes the clock estimates of a set of clients.
+func summarizeClocks(clocks [][][]Clock) (s clockStats) {
+	for _, server := range clocks {
+		for _, channel := range server {
+			for _, c := range channel {
+				offset := c.Offset
+				if offset < 0 {
+					offset = -offset
+				}
+				if offset > s.maxOffset {
+					s.maxOffset = offset
+				}
+				s.maxDrift = math.Max(s.maxDrift, math.Abs(c.Drift))
+				if c.Error > s.maxError {
+					s.maxError = c.Error
+				}
+			}
+		}
+	}
+	return
+}
+
+// broadcastClock returns the clock estimate of the broadcast client of an
+// origin, or nil if there is none.
+func broadcastClock(clocks [][][]Clock, o origin) *Clock {
+	if (int(o.Server) < len(clocks)) &&
+		(int(o.Channel) < len(clocks[o.Server])) &&
+		(int(o.Client) < len(clocks[o.Server][o.Channel])) {
+		return &clocks[o.Server][o.Channel][o.Client]
+	}
+	return nil
+}
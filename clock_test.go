@@ -0,0 +1,92 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	local := time.Unix(1000, 0)
+	tests := []struct {
+		name   string
+		offset time.Duration // Control clock minus client clock
+		drift  float64
+		rtt    [2]time.Duration
+	}{
+		{"synchronized", 0, 0, [2]time.Duration{100, 100}},
+		{"ahead", 5 * time.Second, 0, [2]time.Duration{100, 300}},
+		{"behind and fast", -3 * time.Second, 0.001, [2]time.Duration{500, 200}},
+		{"slow", time.Millisecond, -0.0005, [2]time.Duration{0, 0}},
+	}
+	for _, tt := range tests {
+
+		// The control process starts at the first handshake, and the second
+		// is made 100 seconds (local) later.
+
+		control := local.Add(tt.offset).UnixNano()
+		tStart := time.Unix(0, control)
+		c := newClock(tStart, clockSample{local, control, tt.rtt[0]})
+		if (c.Offset != int64(tt.offset)) || !c.start.Equal(local) {
+			t.Errorf("%s: Offset %d, start %s; want %d, %s",
+				tt.name, c.Offset, c.start, int64(tt.offset), local)
+		}
+
+		elapsed := 100 * time.Second
+		c.finish(clockSample{
+			local.Add(elapsed),
+			control + int64(float64(elapsed)*(1+tt.drift)),
+			tt.rtt[1],
+		})
+		if math.Abs(c.Drift-tt.drift) > 1e-10 {
+			t.Errorf("%s: Drift %v, want %v", tt.name, c.Drift, tt.drift)
+		}
+		rtt := tt.rtt[0]
+		if tt.rtt[1] > rtt {
+			rtt = tt.rtt[1]
+		}
+		if c.Error != uint64(rtt/2) {
+			t.Errorf("%s: Error %d, want %d", tt.name, c.Error, rtt/2)
+		}
+
+		for _, ts := range []uint64{0, uint64(time.Second), uint64(elapsed)} {
+			want := float64(ts) * (1 + tt.drift)
+			if got := c.correct(ts); math.Abs(float64(got)-want) > 1 {
+				t.Errorf("%s: correct(%d) = %d, want %.0f", tt.name, ts, got, want)
+			}
+		}
+	}
+}
+
+func TestSummarizeClocks(t *testing.T) {
+	clocks := [][][]Clock{
+		{{{Offset: 10, Drift: 0.001, Error: 5}}},
+		{{{Offset: -20, Drift: -0.002, Error: 1}, {Offset: 5, Error: 7}}},
+	}
+	s := summarizeClocks(clocks)
+	if (s.maxOffset != 20) || (s.maxDrift != 0.002) || (s.maxError != 7) {
+		t.Errorf("summarizeClocks() = %+v", s)
+	}
+	if c := broadcastClock(clocks, origin{Server: 1, Channel: 0, Client: 1}); c != &clocks[1][0][1] {
+		t.Errorf("broadcastClock() = %p, want %p", c, &clocks[1][0][1])
+	}
+	if c := broadcastClock(clocks, origin{Server: 0, Channel: 0, Client: 1}); c != nil {
+		t.Errorf("broadcastClock() of a missing client = %p, want nil", c)
+	}
+}
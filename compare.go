@@ -0,0 +1,226 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/op/go-logging"
+)
+
+// Results are the headline results of a run, as saved with -results and
+// compared with a baseline. Rates are per second, and latencies are in
+// seconds.
+type Results struct {
+	BroadcastTPS  float64
+	BroadcastBPS  float64
+	DeliverTPS    float64
+	DeliverBPS    float64
+	LatencyMedian float64
+	Latency90     float64
+	Latency95     float64
+	Latency99     float64
+	LatencyMax    float64
+}
+
+// results returns the Results of a Summary.
+func (s *Summary) results() *Results {
+	return &Results{
+		BroadcastTPS:  s.BroadcastTPS,
+		BroadcastBPS:  s.BroadcastBPS,
+		DeliverTPS:    s.DeliverTPS,
+		DeliverBPS:    s.DeliverBPS,
+		LatencyMedian: s.Latency.Seconds(.5),
+		Latency90:     s.Latency.Seconds(.9),
+		Latency95:     s.Latency.Seconds(.95),
+		Latency99:     s.Latency.Seconds(.99),
+		LatencyMax:    s.Latency.Seconds(1),
+	}
+}
+
+// writeResults saves Results as JSON.
+func writeResults(path string, r *Results) error {
+	out, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(out, '\n'), 0644)
+}
+
+// readResults reads Results saved as JSON.
+func readResults(path string) (*Results, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Results{}
+	if err = json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return r, nil
+}
+
+// comparison is the comparison of one metric with its baseline. The change
+// is relative to the baseline.
+type comparison struct {
+	name      string
+	baseline  float64
+	current   float64
+	change    float64
+	regressed bool
+}
+
+// compareResults compares Results with a baseline. Throughput regresses if
+// it falls by more than the throughput tolerance, and latency if it rises by
+// more than the latency tolerance, both as fractions of the baseline. The
+// worst-case latency is too noisy to gate on, and metrics that are 0 in the
+// baseline (e.g., broadcast metrics of a deliver-only baseline) are skipped.
+func compareResults(baseline, current *Results, tpsTolerance, latencyTolerance float64) (rows []comparison) {
+
+	metric := func(name string, b, c float64, higherIsBetter bool) {
+		if b == 0 {
+			return
+		}
+		row := comparison{name: name, baseline: b, current: c, change: (c - b) / b}
+		if higherIsBetter {
+			row.regressed = row.change < -tpsTolerance
+		} else {
+			row.regressed = row.change > latencyTolerance
+		}
+		rows = append(rows, row)
+	}
+
+	metric("Broadcast TPS", baseline.BroadcastTPS, current.BroadcastTPS, true)
+	metric("Broadcast BPS", baseline.BroadcastBPS, current.BroadcastBPS, true)
+	metric("Deliver TPS", baseline.DeliverTPS, current.DeliverTPS, true)
+	metric("Deliver BPS", baseline.DeliverBPS, current.DeliverBPS, true)
+	metric("Latency Median", baseline.LatencyMedian, current.LatencyMedian, false)
+	metric("Latency 90%", baseline.Latency90, current.Latency90, false)
+	metric("Latency 95%", baseline.Latency95, current.Latency95, false)
+	metric("Latency 99%", baseline.Latency99, current.Latency99, false)
+	return
+}
+
+// reportComparison prints a comparison with a baseline, and returns true if
+// any metric regressed.
+func reportComparison(baseline string, rows []comparison) (regressed bool) {
+
+	fmt.Printf("****************************************************************************\n")
+	fmt.Printf("Baseline Comparison: %s\n", baseline)
+	fmt.Printf("    %-16s %14s %14s %9s\n", "Metric", "Baseline", "Current", "Change")
+	value := func(v float64) string {
+		if v >= 1000 {
+			return commafy(int64(v))
+		}
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	}
+	var n int
+	for _, row := range rows {
+		var flag string
+		if row.regressed {
+			flag = "  <<< REGRESSION"
+			n++
+		}
+		fmt.Printf("    %-16s %14s %14s %+8.1f%%%s\n",
+			row.name, value(row.baseline), value(row.current), 100*row.change, flag)
+	}
+	if n == 0 {
+		fmt.Printf("    Result           : PASS\n")
+	} else {
+		fmt.Printf("    Result           : REGRESSION (%d of %d metrics)\n", n, len(rows))
+	}
+	fmt.Printf("****************************************************************************\n")
+	return n != 0
+}
+
+// checkBaseline saves the Results of a run if requested, and compares them
+// with the baseline if one is given. It returns true if the run regressed.
+func checkBaseline(cfg *Config, s *Summary) bool {
+	r := s.results()
+	if cfg.Results != "" {
+		if err := writeResults(cfg.Results, r); err != nil {
+			logger.Errorf("Error writing the results: %s", err)
+		}
+	}
+	if cfg.Baseline == "" {
+		return false
+	}
+	baseline, err := readResults(cfg.Baseline)
+	if err != nil {
+		logger.Fatalf("Error reading the baseline: %s", err)
+	}
+	return reportComparison(cfg.Baseline,
+		compareResults(baseline, r, cfg.TpsTolerance, cfg.LatencyTolerance))
+}
+
+// The results comparator is called as
+//
+//	obx compare [-tpsTolerance <f>] [-latencyTolerance <f>] <baseline> <current>
+//
+// where the baseline and current results were saved by runs with -results.
+// obx exits with status 1 if the current results regressed, so that
+// performance can be gated in CI.
+func compare() {
+
+	logger = logging.MustGetLogger("compare")
+
+	var tpsTolerance, latencyTolerance float64
+	var logLevel string
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+
+	flags.Float64Var(&tpsTolerance, "tpsTolerance", 0.05,
+		"The fraction by which throughput may fall below the baseline; Default 0.05")
+
+	flags.Float64Var(&latencyTolerance, "latencyTolerance", 0.10,
+		"The fraction by which latency may rise above the baseline; Default 0.10")
+
+	flags.StringVar(&logLevel, "logLevel", "info",
+		"The logging level; Default 'info'")
+
+	flags.Parse(os.Args[2:])
+
+	initLogging(logLevel)
+
+	if flags.NArg() != 2 {
+		logger.Fatalf("Usage: obx compare [flags] <baseline> <current>")
+	}
+	if tpsTolerance < 0 {
+		bogus("tpsTolerance", "a non-negative fraction")
+	}
+	if latencyTolerance < 0 {
+		bogus("latencyTolerance", "a non-negative fraction")
+	}
+
+	baseline, err := readResults(flags.Arg(0))
+	if err != nil {
+		logger.Fatalf("Error reading the baseline: %s", err)
+	}
+	current, err := readResults(flags.Arg(1))
+	if err != nil {
+		logger.Fatalf("Error reading the current results: %s", err)
+	}
+
+	if reportComparison(flags.Arg(0),
+		compareResults(baseline, current, tpsTolerance, latencyTolerance)) {
+		os.Exit(1)
+	}
+}
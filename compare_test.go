@@ -0,0 +1,114 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareResults(t *testing.T) {
+	baseline := &Results{
+		BroadcastTPS:  1000,
+		DeliverTPS:    1000,
+		LatencyMedian: 0.1,
+		Latency99:     0.2,
+		LatencyMax:    1,
+	}
+	tests := []struct {
+		name      string
+		current   Results
+		regressed []string
+	}{
+		{
+			name: "same",
+			current: Results{
+				BroadcastTPS: 1000, DeliverTPS: 1000,
+				LatencyMedian: 0.1, Latency99: 0.2, LatencyMax: 1,
+			},
+		},
+		{
+			name: "within tolerance",
+			current: Results{
+				BroadcastTPS: 960, DeliverTPS: 1100,
+				LatencyMedian: 0.109, Latency99: 0.15, LatencyMax: 10,
+			},
+		},
+		{
+			name: "regressed",
+			current: Results{
+				BroadcastTPS: 900, DeliverTPS: 1000,
+				LatencyMedian: 0.1, Latency99: 0.3, LatencyMax: 1,
+			},
+			regressed: []string{"Broadcast TPS", "Latency 99%"},
+		},
+	}
+	for _, tt := range tests {
+		rows := compareResults(baseline, &tt.current, 0.05, 0.1)
+		if len(rows) != 4 {
+			t.Errorf("%s: %d rows, want 4 (metrics that are 0 in the baseline are skipped)",
+				tt.name, len(rows))
+		}
+		var regressed []string
+		for _, row := range rows {
+			if row.regressed {
+				regressed = append(regressed, row.name)
+			}
+		}
+		if len(regressed) != len(tt.regressed) {
+			t.Errorf("%s: regressed %v, want %v", tt.name, regressed, tt.regressed)
+			continue
+		}
+		for i := range regressed {
+			if regressed[i] != tt.regressed[i] {
+				t.Errorf("%s: regressed %v, want %v", tt.name, regressed, tt.regressed)
+				break
+			}
+		}
+	}
+
+	rows := compareResults(baseline, &Results{BroadcastTPS: 500}, 0.05, 0.1)
+	if rows[0].change != -0.5 {
+		t.Errorf("change = %v, want -0.5", rows[0].change)
+	}
+}
+
+func TestResultsRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "results.json")
+	want := &Results{BroadcastTPS: 1234.5, LatencyMedian: 0.25, LatencyMax: 3}
+	if err := writeResults(path, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *want {
+		t.Errorf("readResults() = %+v, want %+v", *got, *want)
+	}
+	if _, err := readResults(filepath.Join(dir, "missing.json")); err == nil {
+		t.Errorf("readResults() of a missing file succeeded")
+	}
+}
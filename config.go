@@ -18,6 +18,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"strings"
 	"time"
 )
@@ -29,6 +30,7 @@ type Config struct {
 
 	ControlAddress   string        // Control process IP address
 	Broadcast        bool          // Will we actually broadcast or just deliver?
+	Discover         bool          // Discover the TX to deliver from the ledger?
 	Bclients         int           // # of broadcast clients
 	Dclients         int           // # of deliver clients
 	Channels         int           // # of channels
@@ -38,31 +40,75 @@ type Config struct {
 	Dservers         []string      // # IP:PORT of deliver servers
 	Transactions     int           // # of transactions per server per client
 	Payload          int           // Payload size in bytes
+	PayloadDist      PayloadDist   // Payload size distribution
+	Content          ContentMode   // Payload content
+	Compression      string        // gRPC transport compression
 	Burst            int           // # of transactions in a burst
 	Delay            time.Duration // Broadcast client delay between bursts
+	MaxInFlight      int           // Max. unacknowledged TX per broadcast client
+	ConnsPerClient   int           // gRPC connections per broadcast client
+	StreamsPerConn   int           // Broadcast streams per connection
+	Phases           PhaseLengths  // Phase lengths (Steady is the rest)
+	RampDelay        time.Duration // Broadcast client delay at the start of the ramp
+	StartBlock       uint64        // Block where deliver clients start
 	Window           int           // # of blocks that can be delivered w/o ACK
 	AckEvery         int           // Deliver clients ack every (this many) blocks
 	Timeout          time.Duration // Initializtion timeout
 	LatencyAll       bool          // Print all latencies (vs. block latencies)?
 	LatencyDir       string        // Directory for latency files
 	LatencyPrefix    string        // Prefix for latency file names
+	StreamVerify     bool          // Verify deliveries without a TX database?
+	ReadBench        string        // Read benchmark mode, or none
+	Seeks            int           // Seeks per deliver client in a read benchmark
+	SeekBlocks       int           // Blocks per seek in a read benchmark
+	ZipfExponent     float64       // Exponent of Zipfian read benchmarks
+	LateJoiners      int           // Late-joining deliver clients per server/channel
+	JoinAt           string        // Join time model of the late joiners
+	SlowConsumers    int           // Slow deliver clients per server/channel
+	ConsumerDelay    string        // Delay model of the slow consumers
 	ControlLogging   string        // Control application logging level
 	BroadcastLogging string        // Broadcast application logging level
 	DeliverLogging   string        // Deliver application logging level
+	Sweep            string        // Sweep specification
+	SweepCSV         string        // File for the sweep summary CSV
+	Timeseries       string        // File for the time series
+	SeriesInterval   time.Duration // Time series interval
+	Results          string        // File for the results of the run
+	Baseline         string        // Results to compare the run against
+	TpsTolerance     float64       // Allowed fractional drop in throughput
+	LatencyTolerance float64       // Allowed fractional rise in latency
+	MonitorPids      []int         // Local processes to monitor
+	MonitorProcess   string        // Name of local processes to monitor
+	MonitorInterval  time.Duration // Process monitoring interval
+
+	WorkloadOverrides []string        // Per-server and per-channel workload overrides
+	ChannelSkew       string          // Channel skew model
+	ChannelWeights    []float64       // Share of each server's TX for each channel
+	JoinMode          string          // Join time model of the late joiners
+	JoinTimes         []time.Duration // Join offsets, or the uniform period
+	JoinFractions     []float64       // Join fractions of the broadcast progress
+	DelayModel        string          // Delay model of the slow consumers
+	DelayMin          time.Duration   // Delay, or the minimum random delay
+	DelayMax          time.Duration   // Delay, or the maximum random delay
 
 	// These fields cache simple computations for convenience
 
-	TotalBroadcastClients   uint64 // The total # of broadcast clients
-	TxBroadcastPerClient    uint64 // # of TX broadcast by each delivery client
-	BytesBroadcastPerClient uint64 // Payload bytes broadcast by each broadcast client
-	TotalTxBroadcast        uint64 // The total # of Tx Broadcast
-	TotalBytesBroadcast     uint64 // Payload bytes (including headers) broadcast
-
-	TotalDeliverClients     uint64 // The total # of deliver clients
-	TxDeliveredPerClient    uint64 // # of TX delivered to each delivery client
-	BytesDeliveredPerClient uint64 // Payload bytes delivered to each delivery client
-	TotalTxDelivered        uint64 // The total # of Tx Delivered
-	TotalBytesDelivered     uint64 // Payload bytes (including headers) delivered
+	Workloads         [][]Workload // Workload of each broadcast server/channel
+	BclientsPerServer []int        // # of broadcast clients of each server
+
+	TotalBroadcastClients uint64 // The total # of broadcast clients
+	TotalTxBroadcast      uint64 // The total # of Tx Broadcast
+	TotalBytesBroadcast   uint64 // Est. payload bytes (including headers) broadcast
+
+	TotalDeliverClients      uint64     // The total # of deliver clients
+	TxBase                   [][]uint64 // Index of each channel/server's first TX
+	TxDeliveredPerChannel    []uint64   // # of TX delivered to each delivery client of a channel
+	BytesDeliveredPerChannel []uint64   // Est. payload bytes delivered to each delivery client of a channel
+	TotalTxDelivered         uint64     // The total # of Tx Delivered
+	TotalBytesDelivered      uint64     // Est. payload bytes (including headers) delivered
+
+	TimedPhases bool   // Are the phase lengths durations (vs. TX counts)?
+	StopBlock   uint64 // Last block discovered, where delivery stops
 }
 
 func bogus(flag string, why string) {
@@ -105,76 +151,217 @@ func requireLE(flag1, flag2 string, val1, val2 int) {
 	}
 }
 
+// requirePhases checks that the phase lengths are either all transaction
+// counts or all durations, and that counts fit within every workload. The
+// workloads are checked after any channel skew, which can leave a light
+// channel with too few TX, so the error names the workload.
+func requirePhases(c *Config) {
+	var counts uint64
+	var timed, counted bool
+	for _, l := range c.Phases {
+		counts += l.Tx
+		timed = timed || (l.Duration != 0)
+		counted = counted || (l.Tx != 0)
+	}
+	if timed && counted {
+		bogus("warmup", "given in the same units as -ramp and -cooldown")
+	}
+	for server, workloads := range c.Workloads {
+		for channel, w := range workloads {
+			if counts > uint64(w.Transactions) {
+				bogus("transactions", fmt.Sprintf(
+					"at least the sum of the phase transaction counts (%d) "+
+						"for every server and channel, after any -channelSkew; "+
+						"Broadcast server %d has %d on channel %d",
+					counts, server, w.Transactions, channel))
+			}
+		}
+	}
+	c.TimedPhases = timed
+}
+
 // Parse and validate the command-line flags and create the configuration.
-func newConfig() *Config {
+// The flags are normally os.Args[1:], however sweeps create a configuration
+// for each run by appending the swept values to the original flags.
+func newConfig(args []string) *Config {
 
 	c := &Config{}
+	flags := flag.NewFlagSet("obx", flag.ExitOnError)
 	var logLevel, bServers, dServers string
+	var warmup, ramp, cooldown, payloadDist, payloadContent string
+	var monitorPids string
+	serverWorkloads := &overrideFlag{name: "serverWorkload"}
+	channelWorkloads := &overrideFlag{name: "channelWorkload"}
 
-	flag.StringVar(&c.ControlAddress, "controlAddress", "localhost:4000",
+	flags.StringVar(&c.ControlAddress, "controlAddress", "localhost:4000",
 		"Control process IP address, default localhost:4000")
 
-	flag.BoolVar(&c.Broadcast, "broadcast", true,
+	flags.BoolVar(&c.Broadcast, "broadcast", true,
 		"Set to false to squash actual broadcast.")
 
-	flag.IntVar(&c.Bclients, "bClients", 1,
+	flags.BoolVar(&c.Discover, "discover", false,
+		"Set to true to deliver only, checking the obx transactions found by first scanning the ledger; The broadcast workload flags are then ignored")
+
+	flags.IntVar(&c.Bclients, "bClients", 1,
 		"The number of broadcast clients; Default 1")
 
-	flag.IntVar(&c.Dclients, "dClients", 1,
+	flags.IntVar(&c.Dclients, "dClients", 1,
 		"The number of deliver clients; Default 1")
 
-	flag.IntVar(&c.Channels, "channels", 1,
+	flags.IntVar(&c.Channels, "channels", 1,
 		"The number of channels; Default 1")
 
-	flag.StringVar(&bServers, "bServers", "",
+	flags.StringVar(&bServers, "bServers", "",
 		"A comma-separated list of IP:PORT of broadcast servers to target; Required")
 
-	flag.StringVar(&dServers, "dServers", "",
+	flags.StringVar(&dServers, "dServers", "",
 		"A comma-separated list of IP:PORT of deliver servers to target; Defaults to broadcast szervers")
 
-	flag.IntVar(&c.Transactions, "transactions", 1,
+	flags.IntVar(&c.Transactions, "transactions", 1,
 		"The number of transactions broadcast to each client's servers; Default 1")
 
-	flag.IntVar(&c.Payload, "payload", TxHeaderSize,
-		"Payload size in bytes; Minimum/default is the performance header size (56 bytes)")
+	flags.IntVar(&c.Payload, "payload", TxHeaderSize,
+		"Payload size in bytes; Minimum/default is the performance header size (62 bytes)")
 
-	flag.IntVar(&c.Burst, "burst", 1,
+	flags.StringVar(&payloadDist, "payloadDist", FixedPayload,
+		"The payload size distribution: fixed (-payload bytes), uniform:MIN,MAX, normal:MEAN,STDDEV, bimodal:SIZE1,SIZE2,PROBABILITY1 or empirical:FILE; Default fixed")
+
+	flags.StringVar(&payloadContent, "payloadContent", ZeroContent,
+		"The payload content: zeros, random, text or ratio:R for a target compression ratio R; Default zeros")
+
+	flags.StringVar(&c.Compression, "compression", NoCompression,
+		"The gRPC transport compression used by the clients: none or gzip; Default none")
+
+	flags.IntVar(&c.Burst, "burst", 1,
 		"The number of transactions burst to each server during broadcast; Dafault 1")
 
-	flag.DurationVar(&c.Delay, "delay", 0,
+	flags.DurationVar(&c.Delay, "delay", 0,
 		"The delay between bursts, in the form required by time.ParseDuration(); Default is no delay")
 
-	flag.IntVar(&c.Window, "window", 100,
+	flags.IntVar(&c.MaxInFlight, "maxInFlight", 0,
+		"The maximum number of unacknowledged transactions of each broadcast client, which blocks while the limit is reached; Default 0 (unlimited)")
+
+	flags.IntVar(&c.ConnsPerClient, "connectionsPerClient", 1,
+		"The number of gRPC connections of each broadcast client; Default 1")
+
+	flags.IntVar(&c.StreamsPerConn, "streamsPerConnection", 1,
+		"The number of broadcast streams on each connection of a broadcast client, which sends to its streams round-robin; Default 1")
+
+	flags.Var(serverWorkloads, "serverWorkload",
+		"Override the workload of a broadcast server as SERVER:KEY=VALUE, where KEY is transactions, payload, payloadDist, burst, delay or bClients; May be repeated")
+
+	flags.Var(channelWorkloads, "channelWorkload",
+		"Override the workload of a channel as CHANNEL:KEY=VALUE, where KEY is transactions, payload, payloadDist, burst or delay; May be repeated")
+
+	flags.StringVar(&c.ChannelSkew, "channelSkew", NoSkew,
+		"The distribution of transactions over channels: none, zipf:S, hot:FRACTION or weights:W0,W1,...; Default none")
+
+	flags.StringVar(&warmup, "warmup", "",
+		"The length of the warm-up phase, as a # of transactions per broadcast client or a duration; Default none")
+
+	flags.StringVar(&ramp, "ramp", "",
+		"The length of the ramp phase, as a # of transactions per broadcast client or a duration; Default none")
+
+	flags.StringVar(&cooldown, "cooldown", "",
+		"The length of the cool-down phase, as a # of transactions per broadcast client or a duration; Default none")
+
+	flags.DurationVar(&c.RampDelay, "rampDelay", 10*time.Millisecond,
+		"The delay between bursts at the start of the ramp phase; Default 10ms")
+
+	flags.Uint64Var(&c.StartBlock, "startBlock", 0,
+		"The block number where deliver clients start delivery; Default 0 (the oldest block)")
+
+	flags.IntVar(&c.Window, "window", 100,
 		"The number of blocks allowed to be delivered without an ACK; Default 100")
 
-	flag.IntVar(&c.AckEvery, "ackEvery", 70,
+	flags.IntVar(&c.AckEvery, "ackEvery", 70,
 		"The deliver client will ACK every (this many) blocks; Default 70")
 
-	flag.DurationVar(&c.Timeout, "timeout", 30*time.Second,
+	flags.DurationVar(&c.Timeout, "timeout", 30*time.Second,
 		"The initialization timeout, in the form required by time.ParseDuration(); Default 30s")
 
-	flag.BoolVar(&c.LatencyAll, "latencyAll", false,
+	flags.BoolVar(&c.LatencyAll, "latencyAll", false,
 		"By default, only block latencies are reported. Set -latencyAll=true to report all transaction latencies")
 
-	flag.StringVar(&c.LatencyDir, "latencyDir", "",
+	flags.StringVar(&c.LatencyDir, "latencyDir", "",
 		"The directory to contain latency files; These files are only created if -latencyDir is specified")
 
-	flag.StringVar(&c.LatencyPrefix, "latencyPrefix", "client",
+	flags.StringVar(&c.LatencyPrefix, "latencyPrefix", "client",
 		"Prefix for latency file names")
 
-	flag.StringVar(&logLevel, "logLevel", "info",
+	flags.StringVar(&c.ReadBench, "readBenchmark", "",
+		"Run a read benchmark of the existing ledger instead of broadcasting, seeking ranges of blocks at random or zipf; Default none")
+
+	flags.IntVar(&c.Seeks, "seeks", 1000,
+		"The # of seeks of each deliver client in a read benchmark; Default 1000")
+
+	flags.IntVar(&c.SeekBlocks, "seekBlocks", 10,
+		"The # of blocks of each seek in a read benchmark; Default 10")
+
+	flags.Float64Var(&c.ZipfExponent, "zipfExponent", 1.1,
+		"The exponent (> 1) of the distribution of zipf read benchmarks; Default 1.1")
+
+	flags.IntVar(&c.LateJoiners, "lateJoiners", 0,
+		"The # of deliver clients of each server and channel that join late, while broadcast continues; Default 0")
+
+	flags.StringVar(&c.JoinAt, "joinAt", "",
+		"When late joiners join: offsets:D0,D1,..., uniform:D or progress:F0,F1,...; Required with -lateJoiners")
+
+	flags.IntVar(&c.SlowConsumers, "slowConsumers", 0,
+		"The # of deliver clients of each server and channel that process blocks slowly; Default 0")
+
+	flags.StringVar(&c.ConsumerDelay, "consumerDelay", "",
+		"The processing delay of slow consumers: block:D, byte:D or random:DMIN,DMAX; Required with -slowConsumers")
+
+	flags.BoolVar(&c.StreamVerify, "streamVerify", false,
+		"Set -streamVerify=true to verify deliveries as they arrive, in memory independent of the # of TX, without correcting for clock drift")
+
+	flags.StringVar(&c.Sweep, "sweep", "",
+		"Run a sweep, e.g., 'payload=100,1000;bClients=1,4' runs each combination of the values; Default no sweep")
+
+	flags.StringVar(&c.SweepCSV, "sweepCSV", "",
+		"The file to contain the sweep summary in CSV form; Default none")
+
+	flags.StringVar(&c.Timeseries, "timeseries", "",
+		"The file to contain the throughput and latency time series of the run, as JSON if the name ends in .json and CSV otherwise; Default none")
+
+	flags.DurationVar(&c.SeriesInterval, "timeseriesInterval", time.Second,
+		"The interval of the time series; Default 1s")
+
+	flags.StringVar(&c.Results, "results", "",
+		"The file to contain the headline results of the run as JSON, for later comparison; Default none")
+
+	flags.StringVar(&c.Baseline, "baseline", "",
+		"Results saved by an earlier run with -results; If given, obx reports the comparison and fails if performance regressed; Default none")
+
+	flags.Float64Var(&c.TpsTolerance, "tpsTolerance", 0.05,
+		"The fraction by which throughput may fall below the baseline; Default 0.05")
+
+	flags.Float64Var(&c.LatencyTolerance, "latencyTolerance", 0.10,
+		"The fraction by which latency may rise above the baseline; Default 0.10")
+
+	flags.StringVar(&monitorPids, "monitorPids", "",
+		"A comma-separated list of IDs of local processes, e.g., orderers, whose resource usage is sampled during the run; Default none")
+
+	flags.StringVar(&c.MonitorProcess, "monitorProcessName", "",
+		"Also sample the local processes with this command name, e.g., orderer; Default none")
+
+	flags.DurationVar(&c.MonitorInterval, "monitorInterval", time.Second,
+		"The sampling interval of monitored processes; Default 1s")
+
+	flags.StringVar(&logLevel, "logLevel", "info",
 		"The global logging level; Default 'info'")
 
-	flag.StringVar(&c.ControlLogging, "controlLogging", "",
+	flags.StringVar(&c.ControlLogging, "controlLogging", "",
 		"Override logging level for the 'control' process")
 
-	flag.StringVar(&c.BroadcastLogging, "broadcastLogging", "",
+	flags.StringVar(&c.BroadcastLogging, "broadcastLogging", "",
 		"Override logging level for the 'broadcast' processes")
 
-	flag.StringVar(&c.DeliverLogging, "deliverLogging", "",
+	flags.StringVar(&c.DeliverLogging, "deliverLogging", "",
 		"Override logging level for the 'deliver' processes")
 
-	flag.Parse()
+	flags.Parse(args)
 
 	if c.ControlLogging == "" {
 		c.ControlLogging = logLevel
@@ -191,9 +378,54 @@ func newConfig() *Config {
 	requireUint16("bclients", c.Bclients)
 	requireUint16("dclients", c.Dclients)
 	requireUint16("channels", c.Channels)
-	requireNonEmpty("bServers", bServers)
-	if dServers == "" {
-		dServers = bServers
+	if (c.ReadBench != "") && (c.ReadBench != RandomReads) && (c.ReadBench != ZipfReads) {
+		bogus("readBenchmark", "either random or zipf")
+	}
+	if c.ReadBench != "" {
+		if c.Discover {
+			bogus("readBenchmark", "unset for -discover runs")
+		}
+		if c.Seeks < 1 {
+			bogus("seeks", "at least 1")
+		}
+		if c.SeekBlocks < 1 {
+			bogus("seekBlocks", "at least 1")
+		}
+		if c.ZipfExponent <= 1 {
+			bogus("zipfExponent", "greater than 1")
+		}
+		c.Broadcast = false
+	}
+	requirePosInt("lateJoiners", c.LateJoiners)
+	if c.LateJoiners != 0 {
+		requireLE("lateJoiners", "dClients", c.LateJoiners, c.Dclients)
+		if c.ReadBench != "" {
+			bogus("lateJoiners", "0 for read benchmarks")
+		}
+		if c.StreamVerify {
+			bogus("lateJoiners", "0 with -streamVerify, which can not split "+
+				"the latencies of the other deliver clients by the catch-ups")
+		}
+		parseJoinAt(c, c.JoinAt)
+	}
+	requirePosInt("slowConsumers", c.SlowConsumers)
+	if c.SlowConsumers != 0 {
+		requireLE("slowConsumers", "dClients", c.SlowConsumers, c.Dclients)
+		if c.ReadBench != "" {
+			bogus("slowConsumers", "0 for read benchmarks")
+		}
+		parseConsumerDelay(c, c.ConsumerDelay)
+	}
+	if c.Discover || (c.ReadBench != "") {
+		if dServers == "" {
+			requireNonEmpty("dServers", bServers)
+			dServers = bServers
+		}
+	} else {
+		requireNonEmpty("bServers", bServers)
+		if dServers == "" {
+			dServers = bServers
+		}
 	}
 	requireUint32("transactions", c.Transactions)
 	requirePosInt("payload", c.Payload)
@@ -202,12 +434,41 @@ func newConfig() *Config {
 			TxHeaderSize)
 		c.Payload = TxHeaderSize
 	}
+	c.PayloadDist = parsePayloadDist(payloadDist, c.Payload)
+	c.Content = parseContentMode(payloadContent)
+	if (c.Compression != NoCompression) && (c.Compression != GzipCompression) {
+		bogus("compression", "either none or gzip")
+	}
 	requirePosInt("burst", c.Burst)
 	requirePosDuration("delay", c.Delay)
+	requirePosInt("maxInFlight", c.MaxInFlight)
+	if c.ConnsPerClient < 1 {
+		bogus("connectionsPerClient", "at least 1")
+	}
+	if c.StreamsPerConn < 1 {
+		bogus("streamsPerConnection", "at least 1")
+	}
+	c.Phases[Warmup] = parsePhaseLength("warmup", warmup)
+	c.Phases[Ramp] = parsePhaseLength("ramp", ramp)
+	c.Phases[Cooldown] = parsePhaseLength("cooldown", cooldown)
+	requirePosDuration("rampDelay", c.RampDelay)
 	requirePosInt("window", c.Window)
 	requirePosInt("ackevery", c.AckEvery)
 	requireLE("ackevery", "window", c.AckEvery, c.Window)
 	requirePosDuration("timeout", c.Timeout)
+	if c.SeriesInterval <= 0 {
+		bogus("timeseriesInterval", "a positive duration")
+	}
+	c.MonitorPids = parsePids(monitorPids)
+	if c.MonitorInterval <= 0 {
+		bogus("monitorInterval", "a positive duration")
+	}
+	if c.TpsTolerance < 0 {
+		bogus("tpsTolerance", "a non-negative fraction")
+	}
+	if c.LatencyTolerance < 0 {
+		bogus("latencyTolerance", "a non-negative fraction")
+	}
 
 	c.Bservers = strings.Split(bServers, ",")
 	c.NumBservers = len(c.Bservers)
@@ -215,6 +476,14 @@ func newConfig() *Config {
 	c.Dservers = strings.Split(dServers, ",")
 	c.NumDservers = len(c.Dservers)
 
+	if c.Discover {
+		c.discover()
+	} else {
+		c.ChannelWeights = parseChannelSkew(c.ChannelSkew, c.Channels)
+		c.setWorkloads(payloadDist, serverWorkloads, channelWorkloads)
+	}
+	requirePhases(c)
+
 	logger.Infof("Configuration")
 	logger.Infof("    Broadcast Servers: %d: %v", c.NumBservers, c.Bservers)
 	logger.Infof("    Broadcast Clients: %d", c.Bclients)
@@ -222,27 +491,60 @@ func newConfig() *Config {
 	logger.Infof("    Deliver Clients  : %d", c.Dclients)
 	logger.Infof("    Channels         : %d", c.Channels)
 	logger.Infof("    Transactions     : %d", c.Transactions)
-	logger.Infof("    Payload          : %d", c.Payload)
+	logger.Infof("    Payload          : %s", c.PayloadDist)
+	logger.Infof("    Payload Content  : %s", c.Content)
+	logger.Infof("    Compression      : %s", c.Compression)
 	logger.Infof("    Burst            : %d", c.Burst)
 	logger.Infof("    Delay            : %s", c.Delay.String())
+	if c.MaxInFlight != 0 {
+		logger.Infof("    Max. In-Flight   : %d", c.MaxInFlight)
+	}
+	if (c.ConnsPerClient != 1) || (c.StreamsPerConn != 1) {
+		logger.Infof("    Connections      : %d x %d streams", c.ConnsPerClient, c.StreamsPerConn)
+	}
+	for _, o := range c.WorkloadOverrides {
+		logger.Infof("    Override         : %s", o)
+	}
+	if c.ChannelWeights != nil {
+		logger.Infof("    Channel Skew     : %s", c.ChannelSkew)
+	}
+	if c.phased() {
+		logger.Infof("    Warm-up          : %s", c.Phases[Warmup])
+		logger.Infof("    Ramp             : %s", c.Phases[Ramp])
+		logger.Infof("    Cool-down        : %s", c.Phases[Cooldown])
+		logger.Infof("    Ramp Delay       : %s", c.RampDelay.String())
+	}
+	if c.StartBlock != 0 {
+		logger.Infof("    Start Block      : %d", c.StartBlock)
+	}
 	logger.Infof("    Window           : %d", c.Window)
 	logger.Infof("    AckEvery         : %d", c.AckEvery)
 	logger.Infof("    Broadcast?       : %v", c.Broadcast)
+	if c.Discover {
+		logger.Infof("    Discovered?      : %v", c.Discover)
+	}
+	if c.LateJoiners != 0 {
+		logger.Infof("    Late Joiners     : %d at %s", c.LateJoiners, c.JoinAt)
+	}
+	if c.SlowConsumers != 0 {
+		logger.Infof("    Slow Consumers   : %d with delay %s", c.SlowConsumers, c.ConsumerDelay)
+	}
+	if c.ReadBench != "" {
+		logger.Infof("    Read Benchmark   : %s, %d seeks of %d blocks", c.ReadBench, c.Seeks, c.SeekBlocks)
+	}
+
+	for channel := 0; channel < c.Channels; channel++ {
+		for server := 0; server < c.NumBservers; server++ {
+			c.TotalBroadcastClients += uint64(c.BclientsPerServer[server])
+		}
+		c.TotalTxBroadcast += c.TxDeliveredPerChannel[channel]
+		c.TotalBytesBroadcast += c.BytesDeliveredPerChannel[channel]
+	}
 
-	c.TotalBroadcastClients =
-		uint64(c.NumBservers) * uint64(c.Channels) * uint64(c.Bclients)
-	c.TxBroadcastPerClient = uint64(c.Transactions)
-	c.BytesBroadcastPerClient = c.TxBroadcastPerClient * uint64(c.Payload)
-	c.TotalTxBroadcast = uint64(c.TotalBroadcastClients) * c.TxBroadcastPerClient
-	c.TotalBytesBroadcast = c.TotalTxBroadcast * uint64(c.Payload)
-
-	c.TotalDeliverClients =
-		uint64(c.NumDservers) * uint64(c.Channels) * uint64(c.Dclients)
-	c.TxDeliveredPerClient =
-		uint64(c.NumBservers) * uint64(c.Bclients) * uint64(c.Transactions)
-	c.BytesDeliveredPerClient = c.TxDeliveredPerClient * uint64(c.Payload)
-	c.TotalTxDelivered = c.TxDeliveredPerClient * c.TotalDeliverClients
-	c.TotalBytesDelivered = c.TotalTxDelivered * uint64(c.Payload)
+	dClientsPerChannel := uint64(c.NumDservers) * uint64(c.Dclients)
+	c.TotalDeliverClients = dClientsPerChannel * uint64(c.Channels)
+	c.TotalTxDelivered = c.TotalTxBroadcast * dClientsPerChannel
+	c.TotalBytesDelivered = c.TotalBytesBroadcast * dClientsPerChannel
 
 	return c
 }
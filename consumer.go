@@ -0,0 +1,166 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/protos/orderer"
+)
+
+// Deliver clients normally receive blocks as fast as they can. With
+// -slowConsumers the first deliver clients of each server and channel
+// simulate slow peers, which spend time processing each block before they
+// receive the next, so that the orderer must buffer blocks for them or
+// throttle them.
+
+// Consumer delay models (-consumerDelay)
+const (
+	BlockDelay  = "block"  // A fixed delay per block
+	ByteDelay   = "byte"   // A delay per byte of the TX of a block
+	RandomDelay = "random" // A delay per block chosen uniformly from a range
+)
+
+// parseConsumerDelay parses the delay model of the slow consumers, which is
+// one of block:D, byte:D or random:DMIN,DMAX.
+func parseConsumerDelay(c *Config, val string) {
+
+	why := "block:D, byte:D or random:DMIN,DMAX"
+
+	kv := strings.SplitN(val, ":", 2)
+	if len(kv) != 2 {
+		bogus("consumerDelay", why)
+	}
+	var args []time.Duration
+	for _, a := range strings.Split(kv[1], ",") {
+		d, err := time.ParseDuration(a)
+		if (err != nil) || (d < 0) {
+			bogus("consumerDelay", why)
+		}
+		args = append(args, d)
+	}
+	c.DelayModel = kv[0]
+	switch {
+	case ((c.DelayModel == BlockDelay) || (c.DelayModel == ByteDelay)) &&
+		(len(args) == 1):
+		c.DelayMin, c.DelayMax = args[0], args[0]
+	case (c.DelayModel == RandomDelay) && (len(args) == 2) && (args[0] <= args[1]):
+		c.DelayMin, c.DelayMax = args[0], args[1]
+	default:
+		bogus("consumerDelay", why)
+	}
+}
+
+// slowConsumer returns true if a deliver client is a slow consumer.
+func (c *Config) slowConsumer(client *Client) bool {
+	return client.Client < c.SlowConsumers
+}
+
+// slowStream is a deliver stream of a slow consumer. Before each block is
+// received, the consumer is delayed for processing the previous block.
+type slowStream struct {
+	orderer.AtomicBroadcast_DeliverClient
+	cfg    *Config
+	random *rand.Rand
+	owed   time.Duration // Processing time of the previous block
+}
+
+// newSlowStream returns the deliver stream of a client, which is slowed
+// down if the client is a slow consumer.
+func newSlowStream(cfg *Config, client *Client,
+	stream orderer.AtomicBroadcast_DeliverClient) orderer.AtomicBroadcast_DeliverClient {
+
+	if !cfg.slowConsumer(client) {
+		return stream
+	}
+	return &slowStream{
+		AtomicBroadcast_DeliverClient: stream,
+		cfg:                           cfg,
+		random:                        rand.New(rand.NewSource(client.seed())),
+	}
+}
+
+func (s *slowStream) Recv() (*orderer.DeliverResponse, error) {
+	if s.owed != 0 {
+		time.Sleep(s.owed)
+		s.owed = 0
+	}
+	reply, err := s.AtomicBroadcast_DeliverClient.Recv()
+	if err != nil {
+		return reply, err
+	}
+	if t, ok := reply.Type.(*orderer.DeliverResponse_Block); ok {
+		switch s.cfg.DelayModel {
+		case BlockDelay:
+			s.owed = s.cfg.DelayMin
+		case ByteDelay:
+			var bytes int
+			for _, data := range t.Block.Data.Data {
+				bytes += len(data)
+			}
+			s.owed = time.Duration(bytes) * s.cfg.DelayMin
+		case RandomDelay:
+			s.owed = s.cfg.DelayMin +
+				time.Duration(s.random.Int63n(int64(s.cfg.DelayMax-s.cfg.DelayMin)+1))
+		}
+	}
+	return reply, err
+}
+
+// reportSlowConsumers prints the delivery lag (TX latency) of the measured
+// phases and the duration of the slow and the fast consumers separately.
+func (s *Stats) reportSlowConsumers(cfg *Config) {
+
+	var slow, fast []float64
+	for server := 0; server < cfg.NumDservers; server++ {
+		for channel := 0; channel < cfg.Channels; channel++ {
+			for client := 0; client < cfg.Dclients; client++ {
+				d := s.Ddeliver[server][channel][client]
+				if cfg.slowConsumer(&Client{Client: client}) {
+					slow = append(slow, d)
+				} else {
+					fast = append(fast, d)
+				}
+			}
+		}
+	}
+
+	fmt.Printf("****************************************************************************\n")
+	fmt.Printf("Slow Consumers (%d per server and channel, delay %s)\n",
+		cfg.SlowConsumers, cfg.ConsumerDelay)
+	fmt.Printf("Consumers          :       Best     Median        90%%        95%%      Worst\n")
+	for _, c := range []struct {
+		title     string
+		durations []float64
+	}{{"Slow", slow}, {"Fast", fast}} {
+		if len(c.durations) == 0 {
+			continue
+		}
+		best, median, p90, p95, worst := percentiles(c.durations, 1)
+		fmt.Printf("    %-4s Dur. Sec. : %10.3f %10.3f %10.3f %10.3f %10.3f\n",
+			c.title, best, median, p90, p95, worst)
+	}
+	if s.SlowLag.N != 0 {
+		printLatency("Slow", &s.SlowLag)
+	}
+	if s.FastLag.N != 0 {
+		printLatency("Fast", &s.FastLag)
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/orderer"
+)
+
+func TestParseConsumerDelay(t *testing.T) {
+	tests := []struct {
+		val      string
+		model    string
+		min, max time.Duration
+	}{
+		{"block:10ms", BlockDelay, 10 * time.Millisecond, 10 * time.Millisecond},
+		{"byte:1us", ByteDelay, time.Microsecond, time.Microsecond},
+		{"random:1ms,5ms", RandomDelay, time.Millisecond, 5 * time.Millisecond},
+		{"random:0s,0s", RandomDelay, 0, 0},
+	}
+	for _, tt := range tests {
+		c := &Config{}
+		parseConsumerDelay(c, tt.val)
+		if (c.DelayModel != tt.model) || (c.DelayMin != tt.min) || (c.DelayMax != tt.max) {
+			t.Errorf("parseConsumerDelay(%q) = %s, %s, %s; want %s, %s, %s", tt.val,
+				c.DelayModel, c.DelayMin, c.DelayMax, tt.model, tt.min, tt.max)
+		}
+	}
+}
+
+// blockStream is a deliver stream that delivers the same block forever.
+type blockStream struct {
+	orderer.AtomicBroadcast_DeliverClient
+	block *common.Block
+}
+
+func (s *blockStream) Recv() (*orderer.DeliverResponse, error) {
+	return &orderer.DeliverResponse{
+		Type: &orderer.DeliverResponse_Block{Block: s.block},
+	}, nil
+}
+
+func TestSlowStream(t *testing.T) {
+	block := &common.Block{Data: &common.BlockData{Data: [][]byte{make([]byte, 100), make([]byte, 50)}}}
+	tests := []struct {
+		val      string
+		min, max time.Duration // Of the delay owed for a block
+	}{
+		{"block:1ms", time.Millisecond, time.Millisecond},
+		{"byte:10ns", 1500 * time.Nanosecond, 1500 * time.Nanosecond},
+		{"random:0s,1us", 0, time.Microsecond},
+	}
+	for _, tt := range tests {
+		c := &Config{SlowConsumers: 1}
+		parseConsumerDelay(c, tt.val)
+		stream := &blockStream{block: block}
+		if s := newSlowStream(c, &Client{Client: 1}, stream); s != stream {
+			t.Errorf("%s: a fast consumer's stream was slowed down", tt.val)
+		}
+		s := newSlowStream(c, &Client{Client: 0}, stream).(*slowStream)
+		for i := 0; i < 10; i++ {
+			if _, err := s.Recv(); err != nil {
+				t.Fatal(err)
+			}
+			if (s.owed < tt.min) || (s.owed > tt.max) {
+				t.Errorf("%s: %s owed, want %s - %s", tt.val, s.owed, tt.min, tt.max)
+			}
+		}
+	}
+}
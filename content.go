@@ -0,0 +1,242 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	crand "crypto/rand"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Payload content kinds
+const (
+	ZeroContent   = "zeros"
+	RandomContent = "random"
+	TextContent   = "text"
+	RatioContent  = "ratio"
+)
+
+// ContentMode describes the content of the transaction payloads following
+// the TxHeader. The default is all zeros, which is maximally compressible.
+// Random content is incompressible, text content compresses about as well as
+// English text, and ratio content is designed to compress by a target ratio.
+type ContentMode struct {
+	Kind  string  // One of the payload content kinds
+	Ratio float64 // Target compression ratio for ratio content
+}
+
+// parseContentMode parses the -payloadContent flag, which is one of
+// zeros, random, text or ratio:R, where R >= 1.
+func parseContentMode(val string) (c ContentMode) {
+	kv := strings.SplitN(val, ":", 2)
+	c.Kind = kv[0]
+	switch {
+	case (len(kv) == 1) &&
+		((c.Kind == ZeroContent) || (c.Kind == RandomContent) ||
+			(c.Kind == TextContent)):
+		return
+	case (len(kv) == 2) && (c.Kind == RatioContent):
+		r, err := strconv.ParseFloat(kv[1], 64)
+		if (err == nil) && (r >= 1) {
+			c.Ratio = r
+			return
+		}
+	}
+	bogus("payloadContent", "one of zeros, random, text or ratio:R with R >= 1")
+	return
+}
+
+func (c ContentMode) String() string {
+	if c.Kind == RatioContent {
+		return c.Kind + " " + strconv.FormatFloat(c.Ratio, 'f', -1, 64)
+	}
+	return c.Kind
+}
+
+// The minimum size of a content pool, and the size of the chunks used to
+// build ratio content.
+const (
+	contentPoolSize  = 4 << 20
+	contentChunkSize = 512
+)
+
+// A small vocabulary for text content
+var contentWords = strings.Fields(`
+the of and to in is that for it as was with be by on not he this are or his
+from at which but have an they you were her she there one all we their been
+has when who will more no if out so said what up its about into than them
+can only other new some could time these two may then do first any my now
+such like our over man me even most made after also did many before must
+through back years where much your way well down should because each just
+those people how too little state good very make world still own see men
+work long get here between both life being under never day same another know
+while last might us great old year off come since against go came right used
+take three ledger block orderer channel transaction broadcast deliver
+`)
+
+// Transport compression kinds
+const (
+	NoCompression   = "none"
+	GzipCompression = "gzip"
+)
+
+// payloadPool is a pool of content bytes that payload bodies are copied from.
+// Each broadcast client generates its own pool, and successive payloads are
+// copied from pseudo-random offsets so that payloads are not identical to
+// each other.
+type payloadPool struct {
+	buf []byte
+	rng *rand.Rand
+}
+
+// newPayloadPool creates a payloadPool for payloads of at most maxSize bytes,
+// or returns nil for zero content, which needs no pool.
+func newPayloadPool(content *ContentMode, maxSize int, seed int64) *payloadPool {
+
+	if content.Kind == ZeroContent {
+		return nil
+	}
+
+	size := contentPoolSize
+	if size < 2*maxSize {
+		size = 2 * maxSize
+	}
+	p := &payloadPool{
+		buf: make([]byte, size),
+		rng: rand.New(rand.NewSource(seed)),
+	}
+
+	switch content.Kind {
+
+	case RandomContent:
+		if _, err := crand.Read(p.buf); err != nil {
+			logger.Fatalf("Error generating random content: %s", err)
+		}
+
+	case TextContent:
+		for i := 0; i < size; {
+			word := contentWords[p.rng.Intn(len(contentWords))]
+			i += copy(p.buf[i:], word)
+			sep := " "
+			switch p.rng.Intn(16) {
+			case 0:
+				sep = ". "
+			case 1:
+				sep = ", "
+			case 2:
+				sep = "\n"
+			}
+			if i < size {
+				i += copy(p.buf[i:], sep)
+			}
+		}
+
+	case RatioContent:
+
+		// Each chunk begins with 1/Ratio of incompressible bytes, and the
+		// rest are zeros.
+
+		random := int(contentChunkSize/content.Ratio + 0.5)
+		for i := 0; i < size; i += contentChunkSize {
+			end := i + random
+			if end > size {
+				end = size
+			}
+			p.rng.Read(p.buf[i:end])
+		}
+	}
+	return p
+}
+
+// fill copies content into a payload body.
+func (p *payloadPool) fill(body []byte) {
+	if p == nil {
+		return
+	}
+	offset := p.rng.Intn(len(p.buf) - len(body) + 1)
+	copy(body, p.buf[offset:])
+}
+
+// measureCompression returns the ratio of the size of a sample of payloads to
+// their total size when each is compressed individually with gzip-compatible
+// deflate, as a transport compressing each message would. Each distinct
+// payload size distribution of the workloads is sampled, and the samples are
+// weighted by the # of TX broadcast with the distribution.
+func measureCompression(cfg *Config) float64 {
+
+	type sample struct {
+		dist *PayloadDist
+		tx   uint64
+	}
+	var samples []*sample
+	for server := range cfg.Workloads {
+		for channel := range cfg.Workloads[server] {
+			w := cfg.workload(server, channel)
+			var s *sample
+			for _, x := range samples {
+				if reflect.DeepEqual(*x.dist, w.PayloadDist) {
+					s = x
+					break
+				}
+			}
+			if s == nil {
+				s = &sample{dist: &w.PayloadDist}
+				samples = append(samples, s)
+			}
+			s.tx += uint64(cfg.BclientsPerServer[server]) * uint64(w.Transactions)
+		}
+	}
+
+	const n = 100
+	var raw, compressed float64
+	for _, s := range samples {
+		r, c := compressSample(&cfg.Content, s.dist, n)
+		raw += float64(s.tx) * float64(r) / n
+		compressed += float64(s.tx) * float64(c) / n
+	}
+	if compressed == 0 {
+		return 1 // Nothing is broadcast
+	}
+	return raw / compressed
+}
+
+// compressSample returns the total size of a sample of n payloads from a
+// distribution, and their total size when each is compressed individually.
+func compressSample(content *ContentMode, dist *PayloadDist, n int) (raw, compressed int) {
+	sizer := newPayloadSizer(dist, &Client{})
+	pool := newPayloadPool(content, dist.Max, 1)
+	data := make([]byte, dist.Max)
+	var header TxHeader
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		payload := data[:sizer.next()]
+		header.Sequence = uint32(i)
+		header.Put(payload)
+		pool.fill(payload[TxHeaderSize:])
+		buf.Reset()
+		w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		w.Write(payload)
+		w.Close()
+		raw += len(payload)
+		compressed += buf.Len()
+	}
+	return
+}
@@ -0,0 +1,60 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMeasureCompression(t *testing.T) {
+	small := parsePayloadDist(FixedPayload, 100)
+	large := parsePayloadDist(FixedPayload, 10000)
+	content := parseContentMode("ratio:4")
+	r, c := compressSample(&content, &small, 100)
+	smallRatio := float64(r) / float64(c)
+	r, c = compressSample(&content, &large, 100)
+	largeRatio := float64(r) / float64(c)
+
+	tests := []struct {
+		name      string
+		workloads [][]Workload
+		want      float64
+	}{
+		{"one workload", [][]Workload{{{Transactions: 10, PayloadDist: large}}}, largeRatio},
+		{"unused override", [][]Workload{
+			{{Transactions: 10, PayloadDist: large}, {Transactions: 0, PayloadDist: small}},
+		}, largeRatio},
+		{"server override", [][]Workload{
+			{{Transactions: 10, PayloadDist: large}},
+			{{Transactions: 10, PayloadDist: small}},
+		}, (100*10000 + 100*100) / (100*10000/largeRatio + 100*100/smallRatio)},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Content: content, Workloads: tt.workloads}
+		cfg.BclientsPerServer = make([]int, len(tt.workloads))
+		for i := range cfg.BclientsPerServer {
+			cfg.BclientsPerServer[i] = 10
+		}
+		if got := measureCompression(cfg); math.Abs(got-tt.want) > 1e-9*tt.want {
+			t.Errorf("%s: measureCompression() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if (smallRatio >= largeRatio) || (largeRatio < 2) {
+		t.Errorf("gzip ratios %v (small) and %v (large)", smallRatio, largeRatio)
+	}
+}
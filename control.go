@@ -36,10 +36,14 @@ import (
 type Control struct {
 	cfg         *Config
 	stats       *Stats
+	mutex       sync.Mutex // Protects stats updates from concurrent RPCs
 	startWG     sync.WaitGroup
 	releaseWG   sync.WaitGroup
 	broadcastWG sync.WaitGroup
 	deliverWG   sync.WaitGroup
+	progressWG  []sync.WaitGroup // Broadcast clients to pass each join fraction
+	joinWG      sync.WaitGroup   // Late joiners to catch up
+	clients     []*exec.Cmd      // The client processes of the current run
 }
 
 // GetConfig is the RPC callback to get the full configuration.
@@ -67,11 +71,58 @@ func (c *Control) Tstart(client *Client, reply *time.Time) error {
 	return nil
 }
 
+// Clock is the RPC callback of the clock handshake, returning the time of the
+// control clock in ns since the epoch.
+func (c *Control) Clock(client *Client, reply *int64) error {
+	*reply = time.Now().UnixNano()
+	return nil
+}
+
+// BroadcastClocks is an RPC callback from deliver clients, returning the
+// clock estimates of the broadcast clients once all of them are done, to
+// correct the broadcast timestamps. No estimates are returned unless the
+// run broadcasts.
+func (c *Control) BroadcastClocks(client *Client, reply *[][][]Clock) error {
+	if !c.cfg.Broadcast {
+		return nil
+	}
+	c.broadcastWG.Wait()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	*reply = c.stats.Bclocks
+	return nil
+}
+
 // BroadcastDone is an RPC callback indicating that a broadcast client is done.
-func (c *Control) BroadcastDone(client *Client, ignore *int) error {
-	logger.Infof("Broadcast client %v signals Done", client)
-	c.stats.Dbroadcast[client.Server][client.Channel][client.Client] =
+func (c *Control) BroadcastDone(client *BroadcastClient, ignore *int) error {
+	logger.Infof("Broadcast client %v signals Done", client.Client)
+	c.mutex.Lock()
+	c.stats.Dbroadcast[client.Server][client.Channel][client.Client.Client] =
 		time.Since(c.stats.Tstart).Seconds()
+	c.stats.Bbytes[client.Server][client.Channel][client.Client.Client] =
+		client.Bytes
+	c.stats.Bclocks[client.Server][client.Channel][client.Client.Client] =
+		client.Clock
+	c.stats.BytesBroadcast += client.Bytes
+	c.stats.Busage = append(c.stats.Busage, client.Usage)
+	c.stats.Bsizes.Merge(&client.Sizes)
+	c.stats.InFlight.Merge(&client.InFlight)
+	c.stats.Blocked += client.Blocked
+	if client.Generation != 0 {
+		tx := c.cfg.workload(client.Server, client.Channel).Transactions
+		generation := float64(client.Generation) / 1e9
+		c.stats.GenRate = append(c.stats.GenRate, float64(tx)/generation)
+		c.stats.GenShare = append(c.stats.GenShare, generation/
+			c.stats.Dbroadcast[client.Server][client.Channel][client.Client.Client])
+	}
+	c.stats.Series.merge(client.Series)
+	for p := range client.Phases {
+		c.stats.Bphases[p].merge(&client.Phases[p])
+	}
+	measured := measuredStats(&client.Phases)
+	c.stats.Bmeasured[client.Server][client.Channel][client.Client.Client] = measured
+	c.stats.Bchannels[client.Channel].merge(&measured)
+	c.mutex.Unlock()
 	c.broadcastWG.Done()
 	return nil
 }
@@ -80,8 +131,18 @@ func (c *Control) BroadcastDone(client *Client, ignore *int) error {
 func (c *Control) DeliverDone(client *DeliverClient, ignore *int) error {
 	logger.Infof("Deliver client %v signals Done; Elapsed time %.3f",
 		client.Client, client.Elapsed)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.stats.Ddeliver[client.Server][client.Channel][client.Client.Client] =
 		client.Elapsed
+	c.stats.Dbytes[client.Server][client.Channel][client.Client.Client] =
+		client.Bytes
+	c.stats.Dclocks[client.Server][client.Channel][client.Client.Client] =
+		client.Clock
+	c.stats.BytesDelivered += client.Bytes
+	c.stats.Dusage = append(c.stats.Dusage, client.Usage)
+	c.stats.Dsizes.Merge(&client.Sizes)
+	c.stats.Series.merge(client.Series)
 	c.stats.Missing += client.Missing
 	c.stats.WrongChannel += client.WrongChannel
 	if c.stats.Missing != 0 {
@@ -95,6 +156,27 @@ func (c *Control) DeliverDone(client *DeliverClient, ignore *int) error {
 	if client.Elapsed > c.stats.DdeliverAll {
 		c.stats.DdeliverAll = client.Elapsed
 	}
+	lag := &c.stats.FastLag
+	if c.cfg.slowConsumer(&client.Client) {
+		lag = &c.stats.SlowLag
+	}
+	for p := range client.Phases {
+		c.stats.Dphases[p].merge(&client.Phases[p])
+	}
+	measured := measuredStats(&client.Phases)
+	c.stats.Dmeasured[client.Server][client.Channel][client.Client.Client] = measured
+	c.stats.Dchannels[client.Channel].merge(&measured)
+	if c.cfg.SlowConsumers != 0 {
+		lag.Merge(&measured.Latency)
+	}
+	if client.LastBlock > c.stats.LastBlock {
+		c.stats.LastBlock = client.LastBlock
+	}
+	c.stats.Blocks.merge(&client.Blocks)
+	c.stats.Reads.merge(&client.Reads)
+	c.stats.JoinerLatency.Merge(&client.JoinerLatency)
+	c.stats.DuringCatchUp.Merge(&client.DuringCatchUp)
+	c.stats.OutsideCatchUp.Merge(&client.OutsideCatchUp)
 	c.deliverWG.Done()
 	return nil
 }
@@ -107,14 +189,20 @@ func (c *Control) Fail(client *ClientFailed, ignore *int) error {
 	return nil
 }
 
-// newControl initializes a Control object from a Config object.
-func newControl(cfg *Config) *Control {
-	c := Control{cfg: cfg, stats: newStats(cfg)}
+// reset initializes a Control object for a run with the given Config.
+func (c *Control) reset(cfg *Config) {
+	c.cfg = cfg
+	c.stats = newStats(cfg)
+	c.clients = nil
 	c.startWG.Add(int(cfg.TotalDeliverClients))
 	c.releaseWG.Add(1)
 	c.broadcastWG.Add(int(cfg.TotalBroadcastClients))
 	c.deliverWG.Add(int(cfg.TotalDeliverClients))
-	return &c
+	c.progressWG = make([]sync.WaitGroup, len(cfg.JoinFractions))
+	for i := range c.progressWG {
+		c.progressWG[i].Add(int(cfg.TotalBroadcastClients))
+	}
+	c.joinWG.Add(cfg.LateJoiners * cfg.NumDservers * cfg.Channels)
 }
 
 // The obx control process
@@ -127,9 +215,8 @@ func control() {
 	// must poll to make sure it is really up and running before starting the
 	// client processes.
 
-	cfg := newConfig()
-	control := newControl(cfg)
-	stats := control.stats
+	cfg := newConfig(os.Args[1:])
+	control := &Control{}
 
 	rpc.Register(control)
 	rpc.HandleHTTP()
@@ -154,6 +241,38 @@ func control() {
 	}
 	rpcOneShot.Stop()
 
+	if cfg.Sweep != "" {
+		sweep(control, cfg)
+		return
+	}
+
+	stats := control.run(cfg)
+	if (stats.Missing != 0) || (stats.WrongChannel != 0) {
+		logger.Fatalf("Aborting due to missing TX and/or channel errors")
+	}
+	if stats.Regressed {
+		logger.Fatalf("Performance regressed against the baseline %s", cfg.Baseline)
+	}
+}
+
+// run executes a single run with the given configuration, prints the report
+// and returns the statistics. The Control object is reused for every run of
+// a sweep.
+func (c *Control) run(cfg *Config) *Stats {
+
+	c.reset(cfg)
+	stats := c.stats
+	var err error
+
+	// Save the configuration with the latency files, for the tools that
+	// analyze them.
+
+	if cfg.LatencyDir != "" {
+		if err = saveConfig(cfg); err != nil {
+			logger.Fatalf("Error saving the configuration: %s", err)
+		}
+	}
+
 	// Start the deliver clients. Once they have all finished seeking, we mark
 	// the start of the run and release them.
 
@@ -176,6 +295,7 @@ func control() {
 					if err != nil {
 						logger.Fatalf("Deliver client start failure: %s", err)
 					}
+					c.clients = append(c.clients, cmd)
 				}
 			}
 		}
@@ -184,12 +304,13 @@ func control() {
 			logger.Fatalf("Deliver clients did not synchronize within %s",
 				cfg.Timeout.String())
 		})
-		control.startWG.Wait()
+		c.startWG.Wait()
 		startOneShot.Stop()
 	}
 
 	stats.Tstart = time.Now()
-	control.releaseWG.Done()
+	procs := startProcMonitor(cfg, stats.Tstart)
+	c.releaseWG.Done()
 
 	// Start the broadcast clients, and wait for completion.
 
@@ -197,7 +318,7 @@ func control() {
 
 		for server := 0; server < cfg.NumBservers; server++ {
 			for channel := 0; channel < cfg.Channels; channel++ {
-				for client := 0; client < cfg.Bclients; client++ {
+				for client := 0; client < cfg.BclientsPerServer[server]; client++ {
 					cmd := exec.Command(
 						os.Args[0],
 						"broadcast",
@@ -212,22 +333,34 @@ func control() {
 					if err != nil {
 						logger.Fatalf("Broadcast client start failure: %s", err)
 					}
+					c.clients = append(c.clients, cmd)
 				}
 			}
 		}
 
-		control.broadcastWG.Wait()
+		c.broadcastWG.Wait()
 		stats.DbroadcastAll = time.Since(stats.Tstart).Seconds()
 	}
 
-	// Nothing to do now but wait for delivery to complete, and print
-	// statistics. Note that deliver clients also do error checking, so their
-	// elapsed times are communicated back through the DeliverDone RPC.
+	// Nothing to do now but wait for delivery to complete, stop monitoring
+	// processes, and print statistics. Note that deliver clients also do
+	// error checking, so their elapsed times are communicated back through
+	// the DeliverDone RPC. The time series, if any, is written after the
+	// report, followed by the results and the comparison with the baseline.
+	// The client processes are reaped before returning.
 
-	control.deliverWG.Wait()
+	c.deliverWG.Wait()
+	stats.Procs = procs.finish()
 	stats.report(cfg)
+	if cfg.Timeseries != "" {
+		if err = writeTimeSeries(cfg, &stats.Series); err != nil {
+			logger.Errorf("Error writing the time series: %s", err)
+		}
+	}
+	stats.Regressed = checkBaseline(cfg, stats.summary(cfg))
 
-	if (stats.Missing != 0) || (stats.WrongChannel != 0) {
-		logger.Fatalf("Aborting due to missing TX and/or channel errors")
+	for _, cmd := range c.clients {
+		cmd.Wait()
 	}
+	return stats
 }
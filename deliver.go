@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"bufio"
 	"fmt"
 	"math"
 	"net/rpc"
@@ -30,7 +31,6 @@ import (
 	"github.com/hyperledger/fabric/orderer/common/bootstrap/provisional"
 	"github.com/hyperledger/fabric/protos/common"
 	"github.com/hyperledger/fabric/protos/orderer"
-	"github.com/hyperledger/fabric/protos/utils"
 
 	"github.com/op/go-logging"
 
@@ -39,11 +39,22 @@ import (
 	"google.golang.org/grpc"
 )
 
+// txRecord is a delivered transaction: its header, including the delivery
+// timestamp, its payload size, and where it was found in the ledger.
+type txRecord struct {
+	TxHeader
+	Size      uint32 // Payload size
+	Index     uint32 // Index of the TX in its block
+	Block     uint64 // Block number
+	BlockSize uint32 // Size of the marshaled block
+}
+
 // The deliver client is called as
 //     obx deliver <control address> <server> <channel> <client>
 func deliver() {
 
 	logger = logging.MustGetLogger("deliver")
+	monitor := newUsageMonitor()
 
 	// Parse args
 
@@ -80,7 +91,7 @@ func deliver() {
 	// Open the gRPC connection to the orderer
 
 	connection, err :=
-		grpc.Dial(cfg.Dservers[server], grpc.WithInsecure())
+		grpc.Dial(cfg.Dservers[server], dialOptions(cfg)...)
 	if err != nil {
 		client.fail(rpcClient,
 			"Deliver client %v could not connect to %s: %s\n",
@@ -94,168 +105,296 @@ func deliver() {
 			client, cfg.Dservers[server], err)
 	}
 
-	// Make the seek request. Then call back to signal that we're ready to
-	// run, obtaining the coordinated start time.
-
-	seek := &common.Envelope{
-		Payload: utils.MarshalOrPanic(&common.Payload{
-			Header: &common.Header{
-				ChainHeader: &common.ChainHeader{
-					ChainID: provisional.TestChainID,
-				},
-				SignatureHeader: &common.SignatureHeader{},
-			},
-			Data: utils.MarshalOrPanic(&orderer.SeekInfo{
-				Start: &orderer.SeekPosition{
-					Type: &orderer.SeekPosition_Oldest{
-						Oldest: &orderer.SeekOldest{},
-					},
-				},
-				Stop: &orderer.SeekPosition{
-					Type: &orderer.SeekPosition_Specified{
-						Specified: &orderer.SeekSpecified{
-							Number: math.MaxUint64},
-					},
-				},
-				Behavior: orderer.SeekInfo_BLOCK_UNTIL_READY,
-			}),
-		}),
+	// A read benchmark makes its own seek requests.
+
+	if cfg.ReadBench != "" {
+		readBenchmark(cfg, client, stream, rpcClient, monitor)
+		return
 	}
 
-	err = stream.Send(seek)
-	if err != nil {
-		client.fail(rpcClient,
-			"Deliver client %v: Failed to send updateSeek: %s",
-			client, err)
+	// Slow consumers are delayed as they receive each block.
+
+	stream = newSlowStream(cfg, client, stream)
+
+	// Make the seek request and the clock handshake. Then call back to
+	// signal that we're ready to run, obtaining the coordinated start time.
+	// Delivery starts from the oldest block unless a start block is
+	// configured. Late joiners make the seek request once they join.
+
+	seek := func() {
+		err := stream.Send(seekEnvelope(provisional.TestChainID,
+			seekStart(cfg), seekStop(cfg)))
+		if err != nil {
+			client.fail(rpcClient,
+				"Deliver client %v: Failed to send updateSeek: %s",
+				client, err)
+		}
+	}
+	_, late := cfg.joinIndex(client)
+	if !late {
+		seek()
 	}
 
+	sample := syncClock(client, rpcClient)
+
 	var tStart time.Time
 	err = rpcClient.Call("Control.Start", client, &tStart)
 	if err != nil {
 		logger.Fatalf("Deliver client %v: RPC Control.Start failed: %s",
 			client, err)
 	}
+	clock := newClock(tStart, sample)
+
+	var tracker *catchUpTracker
+	if late {
+		tracker = joinLate(client, rpcClient, clock)
+		seek()
+	}
+	monitor.begin()
 
-	// Do it
+	// Do it. The TX are verified by a verifier, which also records the
+	// latency files if requested. By default the TX are first recorded in a
+	// database, so that their timestamps can be corrected for the clock drift
+	// before they are verified. With -streamVerify they are verified as they
+	// are delivered, and the memory of the client does not grow with the # of
+	// TX, but the timestamps are not corrected for drift.
+
+	expected := cfg.TxDeliveredPerChannel[channel]
+	done := &DeliverClient{
+		Client: *client,
+		Series: newTimeSeries(cfg),
+	}
+	var latencies *latencyWriter
+	if cfg.LatencyDir != "" {
+		latencies, err = newLatencyWriter(client, cfg)
+		if err != nil {
+			client.fail(rpcClient,
+				"Deliver client %v: Error creating the latency file: %s",
+				client, err)
+		}
+	}
+	v := newVerifier(cfg, done, latencies)
+	v.late = late
+
+	var txDB []txRecord
+	add := v.add
+	if !cfg.StreamVerify {
+		txDB = make([]txRecord, 0, expected)
+		add = func(r *txRecord) {
+			txDB = append(txDB, *r)
+		}
+	}
+	if tracker != nil {
+		verify := add
+		add = func(r *txRecord) {
+			tracker.add(r)
+			verify(r)
+		}
+	}
+	lastBlock, maxMessage := deliverBlocks(client, stream, clock,
+		expected, add, &done.Blocks, func(format string, args ...interface{}) {
+			client.fail(rpcClient, format, args...)
+		})
+
+	tEnd := clock.since() // Final timestamp
+	monitor.end()
+	if tracker != nil {
+		tracker.finish(tEnd)
+	}
+
+	// Estimate the clock drift, and correct the delivery timestamps of the
+	// database for it. The broadcast timestamps are corrected for the drift
+	// of the broadcast clients, once they are all done. Clients that are not
+	// late joiners also wait for the late joiners to catch up, to split their
+	// latencies by whether the TX were broadcast during a catch-up. Then
+	// check the results, that is to say, make sure that the TX received are
+	// the TX expected, and only those. Any errors are reported by the control
+	// process.
+
+	clock.finish(syncClock(client, rpcClient))
+
+	if !cfg.StreamVerify {
+		var bClocks [][][]Clock
+		err = rpcClient.Call("Control.BroadcastClocks", client, &bClocks)
+		if err != nil {
+			logger.Fatalf("Deliver client %v: RPC Control.BroadcastClocks failed: %s",
+				client, err)
+		}
+		if (cfg.LateJoiners != 0) && !late {
+			var catchUps []CatchUp
+			err = rpcClient.Call("Control.CatchUps", client, &catchUps)
+			if err != nil {
+				logger.Fatalf("Deliver client %v: RPC Control.CatchUps failed: %s",
+					client, err)
+			}
+			v.catchUps = catchUps
+		}
+		for i := range txDB {
+			t := &txDB[i]
+			t.Tdelivered = clock.correct(t.Tdelivered)
+			if bc := broadcastClock(bClocks, t.origin()); bc != nil {
+				t.Tbroadcast = bc.correct(t.Tbroadcast)
+			}
+			v.add(t)
+		}
+	}
+	v.finish(expected)
+
+	if latencies != nil {
+		if err := latencies.close(); err != nil {
+			client.fail(rpcClient,
+				"Deliver client %v: Error dumping latencies: %s",
+				client, err)
+		}
+	}
+
+	done.Elapsed = float64(clock.correct(tEnd)) / 1e9
+	done.LastBlock = lastBlock
+	done.Clock = *clock
+	done.Blocks.finish(maxMessage)
+
+	// We're out
+
+	done.Usage = monitor.usage()
+	var ignore int
+	err = rpcClient.Call("Control.DeliverDone", done, &ignore)
+	if err != nil {
+		logger.Fatalf("Deliver client %v: RPC Control.DeliverDone failed: %s",
+			client, err)
+	}
+}
+
+// deliverBlocks receives blocks from a deliver stream until the expected #
+// of obx TX have been delivered, or the stop block of the seek request has
+// been delivered, passing each TX to add with its delivery timestamp. The
+// record passed is reused for the next TX. The blocks holding obx TX are
+// added to the block statistics, which the caller finishes. It returns the
+// number of the last block received and the size of the largest message.
+// fail is called to signal a fatal error. This is
+// shared by deliver clients and the self-test.
+func deliverBlocks(client *Client, stream orderer.AtomicBroadcast_DeliverClient,
+	clock *Clock, expected uint64, add func(r *txRecord), blocks *BlockStats,
+	fail func(format string, args ...interface{})) (lastBlock, maxMessage uint64) {
 
 	var block int
-	var tx uint64
-	txDB := make([]TxHeader, cfg.TxDeliveredPerClient)
-	checkDB := make([]bool, cfg.TxDeliveredPerClient)
+	var tx, lastDelivered uint64
+	var r txRecord
 	envelope := new(common.Envelope)
 	payload := new(common.Payload)
 
-	for tx < cfg.TxDeliveredPerClient {
+	for tx < expected {
 
 		reply, err := stream.Recv()
 		if err != nil {
-			client.fail(rpcClient,
-				"Deliver client %v: Reply error at block %d: %s",
+			fail("Deliver client %v: Reply error at block %d: %s",
 				client, block, err)
 		}
 
 		switch t := reply.Type.(type) {
 		case *orderer.DeliverResponse_Block:
 
-			timestamp := uint64(time.Since(tStart))
+			timestamp := clock.since()
 
 			logger.Debugf("Block %v", t)
 			logger.Debugf("Deliver client %v: Block %d @ TX %d holds %d new TX",
 				client, t.Block.Header.Number, tx, len(t.Block.Data.Data))
 
 			block++
+			lastBlock = t.Block.Header.Number
+			blockSize := uint32(proto.Size(t.Block))
+			record := blockRecord{tx: uint64(len(t.Block.Data.Data))}
+			first := uint64(math.MaxUint64)
 
-			for _, transaction := range t.Block.Data.Data {
+			for i, transaction := range t.Block.Data.Data {
 				err := proto.Unmarshal(transaction, envelope)
 				if err != nil {
-					client.fail(rpcClient,
-						"Unmarshal to Envelope failed: %s", err)
+					fail("Unmarshal to Envelope failed: %s", err)
 				} else {
 					err = proto.Unmarshal(envelope.Payload, payload)
 					if err != nil {
-						client.fail(rpcClient,
-							"Unmarshal to Payload failed: %s", err)
+						fail("Unmarshal to Payload failed: %s", err)
+					}
+					size := uint64(len(envelope.Payload) + len(envelope.Signature))
+					record.bytes += size
+					if size > maxMessage {
+						maxMessage = size
 					}
 					message := payload.Data
-					if len(message) != cfg.Payload {
+					if !isTx(message) {
 						logger.Debugf(
 							"Deliver client %v: "+
-								"Unexpected message size %d at TX %d; "+
+								"Non-obx message of size %d at TX %d; "+
 								"Message ignored",
 							client, len(message), tx)
 						continue // Genesis messages are ignored
 					}
-					txDB[tx].Get(message)
-					txDB[tx].Tdelivered = timestamp
-					logger.Debugf("Deliver client %v: Header: %v", client, txDB[tx])
-					tx++
-					if tx == cfg.TxDeliveredPerClient {
-						break
+					if tx == expected {
+						continue // Beyond the TX expected, but counted in the block size
+					}
+					r.Get(message)
+					r.Tdelivered = timestamp
+					r.Size = uint32(len(message))
+					r.Index = uint32(i)
+					r.Block = lastBlock
+					r.BlockSize = blockSize
+					logger.Debugf("Deliver client %v: Header: %v", client, r.TxHeader)
+					if r.Tbroadcast < first {
+						first = r.Tbroadcast
 					}
+					add(&r)
+					tx++
 				}
 			}
 
-		case *orderer.DeliverResponse_Status:
-			client.fail(rpcClient,
-				"Deliver client %v: Orderer delivered status response: %s",
-				client, t.Status.String())
-		}
-	}
-
-	elapsed := time.Since(tStart).Seconds() // Final timestamp
+			// Blocks holding obx TX are recorded for the block statistics.
 
-	// Check the results, that is to say, make sure that the TX received are
-	// the TX expected, and only those. Any errors are reported by the control
-	// process.
-
-	var wrongChannel, missing uint64
-	for tx = 0; tx < cfg.TxDeliveredPerClient; tx++ {
-		t := &txDB[tx]
-		x :=
-			(uint64(t.Server) * uint64(cfg.Bclients) * uint64(cfg.Transactions)) +
-				(uint64(t.Client) * uint64(cfg.Transactions)) +
-				uint64(t.Sequence)
-		checkDB[x] = true
-		if int(t.Channel) != channel {
-			wrongChannel++
-		}
-	}
-	for tx = 0; tx < cfg.TxDeliveredPerClient; tx++ {
-		if !checkDB[tx] {
-			missing++
-		}
-	}
-
-	// If the user requested latency statistics, dump them.
+			if first != math.MaxUint64 {
+				if lastDelivered != 0 {
+					record.gap = timestamp - lastDelivered
+				}
+				if timestamp > first {
+					record.formation = timestamp - first
+				}
+				lastDelivered = timestamp
+				blocks.add(&record)
+			}
 
-	if cfg.LatencyDir != "" {
-		if err := dumpLatencies(client, cfg, txDB); err != nil {
-			client.fail(rpcClient,
-				"Deliver client %v: Error dumping latencies: %s",
-				client, err)
+		case *orderer.DeliverResponse_Status:
+			if t.Status == common.Status_SUCCESS {
+				return // The stop block was delivered; Any TX not seen are missing
+			}
+			fail("Deliver client %v: Orderer delivered status response: %s",
+				client, t.Status.String())
 		}
 	}
+	return
+}
 
-	// We're out
-
-	done := &DeliverClient{
-		Client:       *client,
-		Elapsed:      elapsed,
-		Missing:      missing,
-		WrongChannel: wrongChannel,
-	}
-	var ignore int
-	err = rpcClient.Call("Control.DeliverDone", done, &ignore)
-	if err != nil {
-		logger.Fatalf("Deliver client %v: RPC Control.DeliverDone failed: %s",
-			client, err)
-	}
+// latencyWriter streams latency statistics to a CSV file as TX are verified.
+// The default is to report summary statistics for each block: the number of
+// TX checked and the size of the block, the earliest broadcast and the
+// delivery timestamps, the block formation latency from the earliest
+// broadcast to delivery, the gap since the previous block was delivered, and
+// the minimum and maximum TX latency. But if requested we can also print all
+// latencies. The TX of a block arrive together, so a block is summarized when
+// the first TX of the next block arrives, or the file is closed.
+type latencyWriter struct {
+	f   *os.File
+	w   *bufio.Writer
+	all bool
+
+	// The block being summarized
+
+	block      txRecord // The first TX of the block
+	n          int      // # of TX in the block
+	first      uint64   // Earliest broadcast timestamp
+	minLatency uint64
+	maxLatency uint64
+	previous   uint64 // Delivery timestamp of the previous block
 }
 
-// Dump latency statistics to a CSV file. The default is to report summary
-// statistics for blocks, where blocks are inferred by the delivery
-// timestamps. But if requested we can also print all latencies.
-func dumpLatencies(client *Client, cfg *Config, txDB []TxHeader) (err error) {
+// newLatencyWriter creates the latency file of a deliver client.
+func newLatencyWriter(client *Client, cfg *Config) (*latencyWriter, error) {
 	fileName :=
 		cfg.LatencyPrefix + "." +
 			strconv.Itoa(client.Server) + "." +
@@ -265,62 +404,82 @@ func dumpLatencies(client *Client, cfg *Config, txDB []TxHeader) (err error) {
 	path := filepath.Join(cfg.LatencyDir, fileName)
 	f, err := os.Create(path)
 	if err != nil {
-		return
+		return nil, err
 	}
-	defer f.Close()
-
-	if cfg.LatencyAll {
-
-		fmt.Fprintf(f,
-			"Server,Channel,Client,Sequence,Tbroadcast,Tdelivered,Latency\n")
-		for _, tx := range txDB {
-			fmt.Fprintf(f, "%d,%d,%d,%d,%.9f,%.9f,%.9f\n",
-				tx.Server, tx.Channel, tx.Client, tx.Sequence,
-				float64(tx.Tbroadcast)/1e9, float64(tx.Tdelivered)/1e9,
-				float64(tx.Tdelivered-tx.Tbroadcast)/1e9)
-		}
-
+	l := &latencyWriter{f: f, w: bufio.NewWriter(f), all: cfg.LatencyAll}
+	if l.all {
+		fmt.Fprintf(l.w,
+			"Server,Channel,Client,Sequence,Tbroadcast,Tdelivered,Latency,Size,Block,Index\n")
 	} else {
+		fmt.Fprintf(l.w,
+			"Block,NumTX,Bytes,Tfirst,Tdelivered,Formation,Gap,MinLatency,MaxLatency\n")
+	}
+	return l, nil
+}
 
-		fmt.Fprintf(f, "Block,NumTX,Tdelivered,MinLatency,MaxLatency\n")
-
-		var block, numTX, blockTimestamp, minLatency, maxLatency uint64
-		minLatency = 0xffffffffffffffff
-
-		var tx TxHeader
-		for _, tx = range txDB {
-
-			if tx.Tdelivered != blockTimestamp {
-
-				print := blockTimestamp != 0
-				blockTimestamp = tx.Tdelivered
+// add records the latency of a TX.
+func (l *latencyWriter) add(tx *txRecord) {
 
-				if print {
+	if l.all {
+		fmt.Fprintf(l.w, "%d,%d,%d,%d,%.9f,%.9f,%.9f,%d,%d,%d\n",
+			tx.Server, tx.Channel, tx.Client, tx.Sequence,
+			float64(tx.Tbroadcast)/1e9, float64(tx.Tdelivered)/1e9,
+			float64(tx.latency())/1e9, tx.Size,
+			tx.Block, tx.Index)
+		return
+	}
 
-					fmt.Fprintf(f, "%d,%d,%.9fd,%.9f,%.9f\n",
-						block, numTX, float64(tx.Tdelivered)/1e9,
-						float64(minLatency)/1e9, float64(maxLatency)/1e9)
+	if (l.n != 0) && (tx.Block != l.block.Block) {
+		l.summarize()
+	}
+	if l.n == 0 {
+		l.block = *tx
+		l.first = tx.Tbroadcast
+		l.minLatency = math.MaxUint64
+		l.maxLatency = 0
+	}
+	l.n++
+	if tx.Tbroadcast < l.first {
+		l.first = tx.Tbroadcast
+	}
+	latency := tx.latency()
+	if latency > l.maxLatency {
+		l.maxLatency = latency
+	}
+	if latency < l.minLatency {
+		l.minLatency = latency
+	}
+}
 
-					block++
-					numTX = 0
-					minLatency = 0xffffffffffffffff
-					maxLatency = 0
-				}
-			}
+// summarize prints the summary of the current block.
+func (l *latencyWriter) summarize() {
 
-			numTX++
-			latency := tx.Tdelivered - tx.Tbroadcast
-			if latency > maxLatency {
-				maxLatency = latency
-			}
-			if latency < minLatency {
-				minLatency = latency
-			}
+	b := &l.block
+	var formation, gap uint64
+	if b.Tdelivered > l.first {
+		formation = b.Tdelivered - l.first
+	}
+	if l.previous != 0 {
+		gap = b.Tdelivered - l.previous
+	}
+	l.previous = b.Tdelivered
+
+	fmt.Fprintf(l.w, "%d,%d,%d,%.9f,%.9f,%.9f,%.9f,%.9f,%.9f\n",
+		b.Block, l.n, b.BlockSize,
+		float64(l.first)/1e9, float64(b.Tdelivered)/1e9,
+		float64(formation)/1e9, float64(gap)/1e9,
+		float64(l.minLatency)/1e9, float64(l.maxLatency)/1e9)
+	l.n = 0
+}
 
-		}
-		fmt.Fprintf(f, "%d,%d,%.9fd,%.9f,%.9f\n",
-			block, numTX, float64(tx.Tdelivered)/1e9,
-			float64(minLatency)/1e9, float64(maxLatency)/1e9)
+// close summarizes the last block and closes the file.
+func (l *latencyWriter) close() error {
+	if l.n != 0 {
+		l.summarize()
 	}
-	return
+	err := l.w.Flush()
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
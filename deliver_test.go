@@ -0,0 +1,176 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// dumpLatencies writes the latency file of a deliver client from all of its
+// TX, as deliver clients did before latency files were streamed.
+func dumpLatencies(f io.Writer, cfg *Config, txDB []txRecord) {
+
+	if cfg.LatencyAll {
+
+		fmt.Fprintf(f,
+			"Server,Channel,Client,Sequence,Tbroadcast,Tdelivered,Latency,Size,Block,Index\n")
+		for _, tx := range txDB {
+			fmt.Fprintf(f, "%d,%d,%d,%d,%.9f,%.9f,%.9f,%d,%d,%d\n",
+				tx.Server, tx.Channel, tx.Client, tx.Sequence,
+				float64(tx.Tbroadcast)/1e9, float64(tx.Tdelivered)/1e9,
+				float64(tx.latency())/1e9, tx.Size,
+				tx.Block, tx.Index)
+		}
+
+	} else {
+
+		fmt.Fprintf(f,
+			"Block,NumTX,Bytes,Tfirst,Tdelivered,Formation,Gap,MinLatency,MaxLatency\n")
+
+		// The TX of a block are contiguous in the database.
+
+		var previous uint64 // Delivery timestamp of the previous block
+		for start := 0; start < len(txDB); {
+
+			b := &txDB[start]
+			first := b.Tbroadcast
+			minLatency := uint64(math.MaxUint64)
+			maxLatency := uint64(0)
+			end := start
+			for ; (end < len(txDB)) && (txDB[end].Block == b.Block); end++ {
+				tx := &txDB[end]
+				if tx.Tbroadcast < first {
+					first = tx.Tbroadcast
+				}
+				latency := tx.latency()
+				if latency > maxLatency {
+					maxLatency = latency
+				}
+				if latency < minLatency {
+					minLatency = latency
+				}
+			}
+
+			var formation, gap uint64
+			if b.Tdelivered > first {
+				formation = b.Tdelivered - first
+			}
+			if previous != 0 {
+				gap = b.Tdelivered - previous
+			}
+			previous = b.Tdelivered
+
+			fmt.Fprintf(f, "%d,%d,%d,%.9f,%.9f,%.9f,%.9f,%.9f,%.9f\n",
+				b.Block, end-start, b.BlockSize,
+				float64(first)/1e9, float64(b.Tdelivered)/1e9,
+				float64(formation)/1e9, float64(gap)/1e9,
+				float64(minLatency)/1e9, float64(maxLatency)/1e9)
+
+			start = end
+		}
+	}
+}
+
+func TestLatencyWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Blocks of 1 to 5 TX, broadcast out of order, with the occasional TX
+	// delivered before its (skewed) broadcast timestamp.
+
+	var txDB []txRecord
+	var tDelivered uint64 = 1e9
+	for block := uint64(3); block < 20; block++ {
+		tDelivered += block * 12345678
+		n := int(block%5) + 1
+		for i := 0; i < n; i++ {
+			tBroadcast := tDelivered - uint64((i*7919)%n+1)*1000000
+			if (block == 7) && (i == 0) {
+				tBroadcast = tDelivered + 1000
+			}
+			tx := txRecord{Index: uint32(i), Block: block, BlockSize: uint32(n * 100)}
+			tx.Tbroadcast = tBroadcast
+			tx.Tdelivered = tDelivered
+			tx.Client = uint16(i)
+			tx.Sequence = uint32(block)
+			tx.Size = 100
+			txDB = append(txDB, tx)
+		}
+	}
+
+	tests := []struct {
+		name string
+		txDB []txRecord
+		all  bool
+	}{
+		{"blocks", txDB, false},
+		{"all", txDB, true},
+		{"one block", txDB[:1], false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		cfg := &Config{LatencyDir: dir, LatencyPrefix: "test", LatencyAll: tt.all}
+		l, err := newLatencyWriter(&Client{Server: 1, Channel: 2, Client: 3}, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range tt.txDB {
+			l.add(&tt.txDB[i])
+		}
+		if err := l.close(); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadFile(filepath.Join(dir, "test.1.2.3.csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want bytes.Buffer
+		dumpLatencies(&want, cfg, tt.txDB)
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("%s: latency file\n%s\nwant\n%s", tt.name, got, want.Bytes())
+		}
+
+		// TX delivered before their (skewed) broadcast time have 0 latency.
+
+		f := &latencyFile{Path: filepath.Join(dir, "test.1.2.3.csv")}
+		err = f.scan(
+			func(r *latencyTx) {
+				if (r.Latency < 0) || (r.Latency > 1) {
+					t.Errorf("%s: TX %d has latency %v", tt.name, r.Sequence, r.Latency)
+				}
+			},
+			func(r *latencyBlock) {
+				if (r.MinLatency < 0) || (r.MaxLatency > 1) {
+					t.Errorf("%s: block %d has latencies %v - %v",
+						tt.name, r.Block, r.MinLatency, r.MaxLatency)
+				}
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/hyperledger/fabric/orderer/common/bootstrap/provisional"
+	"github.com/hyperledger/fabric/protos/common"
+)
+
+// discoveredOrigin summarizes the transactions of one broadcast client on
+// one channel found by a ledger scan.
+type discoveredOrigin struct {
+	count uint64   // # of TX found
+	next  uint64   // One more than the highest sequence number found
+	bytes uint64   // Payload bytes found
+	seen  []uint64 // Bitmap of the sequence numbers found
+}
+
+// add records a TX found, and returns false if its sequence number was
+// already found.
+func (d *discoveredOrigin) add(sequence uint32, bytes int) bool {
+	word, bit := int(sequence/64), uint(sequence%64)
+	if word >= len(d.seen) {
+		d.seen = append(d.seen, make([]uint64, word+1-len(d.seen))...)
+	}
+	if (d.seen[word] & (1 << bit)) != 0 {
+		return false
+	}
+	d.seen[word] |= 1 << bit
+	d.count++
+	d.bytes += uint64(bytes)
+	if uint64(sequence) >= d.next {
+		d.next = uint64(sequence) + 1
+	}
+	return true
+}
+
+// discover scans the ledger of the first deliver server for obx
+// transactions, from the start block through the newest block, and sets up
+// the workloads and TX layout from the origins recorded in their headers.
+// This allows a deliver-only run to check the transactions delivered without
+// repeating the flags of the run that broadcast them.
+//
+// The numbers of broadcast servers, clients and channels are the highest
+// found. The # of transactions of each broadcast server on each channel is
+// the highest sequence number found for any of its clients, and the payload
+// size is the mean size found. The deliver clients stop at the last block
+// found, so any origin whose transactions are incomplete is reported here,
+// and its gaps show up as missing TX after the run. A ledger holding the
+// transactions of more than one run repeats origins and sequence numbers,
+// which can not be checked, so discovery fails unless -startBlock skips the
+// earlier runs.
+func (c *Config) discover() {
+
+	origins := make(map[origin]*discoveredOrigin)
+	var blocks, firstBlock, lastBlock, other, duplicates uint64
+	var duplicate TxHeader
+	var header TxHeader
+
+	err := scanLedger(c, c.Dservers[0], provisional.TestChainID,
+		seekStart(c), seekNewest(),
+		func(block *common.Block) error {
+			if blocks == 0 {
+				firstBlock = block.Header.Number
+			}
+			blocks++
+			lastBlock = block.Header.Number
+			messages, err := blockMessages(block)
+			if err != nil {
+				return err
+			}
+			for _, message := range messages {
+				if !isTx(message) {
+					other++
+					continue
+				}
+				header.Get(message)
+				o := origins[header.origin()]
+				if o == nil {
+					o = &discoveredOrigin{}
+					origins[header.origin()] = o
+				}
+				if !o.add(header.Sequence, len(message)) {
+					if duplicates == 0 {
+						duplicate = header
+					}
+					duplicates++
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		logger.Fatalf("Ledger discovery on %s failed: %s", c.Dservers[0], err)
+	}
+	if len(origins) == 0 {
+		logger.Fatalf("Ledger discovery on %s found no obx transactions "+
+			"in %d blocks", c.Dservers[0], blocks)
+	}
+	if duplicates != 0 {
+		logger.Fatalf("Ledger discovery on %s found %d duplicate obx "+
+			"transactions in blocks %d through %d, e.g. TX %d of broadcast "+
+			"client %d of server %d on channel %d; The blocks hold more than "+
+			"one run, so use -startBlock to discover only the latest run",
+			c.Dservers[0], duplicates, firstBlock, lastBlock, duplicate.Sequence,
+			duplicate.Client, duplicate.Server, duplicate.Channel)
+	}
+
+	// Size the configuration to hold every origin found.
+
+	c.NumBservers, c.Bclients, c.Channels = 0, 0, 0
+	for o := range origins {
+		if int(o.Server) >= c.NumBservers {
+			c.NumBservers = int(o.Server) + 1
+		}
+		if int(o.Client) >= c.Bclients {
+			c.Bclients = int(o.Client) + 1
+		}
+		if int(o.Channel) >= c.Channels {
+			c.Channels = int(o.Channel) + 1
+		}
+	}
+	c.Bservers = nil
+	c.BclientsPerServer = make([]int, c.NumBservers)
+	c.Workloads = make([][]Workload, c.NumBservers)
+	for server := range c.Workloads {
+		c.Workloads[server] = make([]Workload, c.Channels)
+	}
+
+	var found, bytes uint64
+	for o, d := range origins {
+		server := int(o.Server)
+		if int(o.Client) >= c.BclientsPerServer[server] {
+			c.BclientsPerServer[server] = int(o.Client) + 1
+		}
+		w := c.workload(server, int(o.Channel))
+		if int(d.next) > w.Transactions {
+			w.Transactions = int(d.next)
+		}
+		found += d.count
+		bytes += d.bytes
+	}
+
+	// The workloads are filled in from the global flags, except for the
+	// payload size, which is the mean size found.
+
+	c.Transactions = 0
+	c.Payload = int(bytes / found)
+	c.PayloadDist = parsePayloadDist(FixedPayload, c.Payload)
+	for server := range c.Workloads {
+		for channel := range c.Workloads[server] {
+			w := c.workload(server, channel)
+			w.PayloadDist = c.PayloadDist
+			w.Burst = c.Burst
+			w.Delay = c.Delay
+			if w.Transactions > c.Transactions {
+				c.Transactions = w.Transactions
+			}
+		}
+	}
+	c.setLayout()
+
+	// The byte counts are known exactly. Incomplete origins are reported,
+	// including broadcast clients that were not found at all.
+
+	for channel := range c.BytesDeliveredPerChannel {
+		c.BytesDeliveredPerChannel[channel] = 0
+	}
+	var expected uint64
+	var total, incomplete int
+	for server := 0; server < c.NumBservers; server++ {
+		for channel := 0; channel < c.Channels; channel++ {
+			tx := uint64(c.workload(server, channel).Transactions)
+			for client := 0; client < c.BclientsPerServer[server]; client++ {
+				expected += tx
+				total++
+				o := origin{Server: uint16(server), Channel: uint16(channel),
+					Client: uint16(client)}
+				d := origins[o]
+				if d == nil {
+					d = &discoveredOrigin{}
+				} else {
+					c.BytesDeliveredPerChannel[channel] += d.bytes
+				}
+				if d.count != tx {
+					incomplete++
+					logger.Warningf("Discovery: Broadcast client %d of server %d "+
+						"on channel %d has %d of %d TX",
+						client, server, channel, d.count, tx)
+				}
+			}
+		}
+	}
+
+	logger.Infof("Discovered %d obx TX (%d expected) and %d other TX "+
+		"in blocks %d through %d", found, expected, other, firstBlock, lastBlock)
+	if incomplete != 0 {
+		logger.Warningf("Discovery: %d of %d origins are incomplete; "+
+			"Their gaps will be reported as missing TX", incomplete, total)
+	}
+
+	c.StopBlock = lastBlock
+	c.Broadcast = false
+}
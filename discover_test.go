@@ -0,0 +1,50 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestDiscoveredOrigin(t *testing.T) {
+	tests := []struct {
+		sequences  []uint32
+		count      uint64
+		next       uint64
+		duplicates int
+	}{
+		{[]uint32{0, 1, 2}, 3, 3, 0},
+		{[]uint32{5, 0, 200}, 3, 201, 0},
+		{[]uint32{0, 1, 0, 1, 2}, 3, 3, 2},
+		{[]uint32{63, 64, 64, 1000000}, 3, 1000001, 1},
+	}
+	for _, tt := range tests {
+		var d discoveredOrigin
+		var duplicates int
+		for _, seq := range tt.sequences {
+			if !d.add(seq, 100) {
+				duplicates++
+			}
+		}
+		if (d.count != tt.count) || (d.next != tt.next) || (d.bytes != 100*tt.count) ||
+			(duplicates != tt.duplicates) {
+			t.Errorf("%v: count %d, next %d, bytes %d, %d duplicates; want %d, %d, %d, %d",
+				tt.sequences, d.count, d.next, d.bytes, duplicates,
+				tt.count, tt.next, 100*tt.count, tt.duplicates)
+		}
+	}
+}
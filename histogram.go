@@ -0,0 +1,154 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"math"
+)
+
+// Histogram is a compact, mergeable histogram of non-negative integer values,
+// normally durations in nanoseconds. Values are binned into power-of-2 ranges,
+// each of which is split into histogramSubBuckets linear sub-buckets, so
+// quantiles are accurate to within about 1.5%. Clients build histograms and
+// ship them to the control process (gob-encoded) where they are merged.
+type Histogram struct {
+	Counts []uint64 // Bucket counts, grown on demand
+	N      uint64   // # of values recorded
+	Sum    float64  // Sum of values recorded
+	Min    uint64   // Smallest value recorded
+	Max    uint64   // Largest value recorded
+}
+
+const (
+	histogramSubBits    = 6
+	histogramSubBuckets = 1 << histogramSubBits
+)
+
+// histogramBucket returns the bucket index of a value. Values below
+// histogramSubBuckets are binned exactly.
+func histogramBucket(v uint64) int {
+	if v < histogramSubBuckets {
+		return int(v)
+	}
+	var shift uint
+	for (v >> shift) >= 2*histogramSubBuckets {
+		shift++
+	}
+	return int(shift+1)*histogramSubBuckets +
+		int(v>>shift) - histogramSubBuckets
+}
+
+// histogramValue returns the midpoint of the range of values covered by a
+// bucket.
+func histogramValue(b int) uint64 {
+	if b < histogramSubBuckets {
+		return uint64(b)
+	}
+	shift := uint(b/histogramSubBuckets - 1)
+	low := uint64(b%histogramSubBuckets+histogramSubBuckets) << shift
+	return low + (uint64(1)<<shift)/2
+}
+
+// Record adds a value to the histogram.
+func (h *Histogram) Record(v uint64) {
+	h.RecordN(v, 1)
+}
+
+// RecordN adds n occurrences of a value to the histogram.
+func (h *Histogram) RecordN(v, n uint64) {
+	if n == 0 {
+		return
+	}
+	b := histogramBucket(v)
+	if b >= len(h.Counts) {
+		h.Counts = append(h.Counts, make([]uint64, b+1-len(h.Counts))...)
+	}
+	h.Counts[b] += n
+	if (h.N == 0) || (v < h.Min) {
+		h.Min = v
+	}
+	if v > h.Max {
+		h.Max = v
+	}
+	h.N += n
+	h.Sum += float64(v) * float64(n)
+}
+
+// Merge adds the contents of another histogram to this one.
+func (h *Histogram) Merge(o *Histogram) {
+	if o.N == 0 {
+		return
+	}
+	if len(o.Counts) > len(h.Counts) {
+		h.Counts = append(h.Counts, make([]uint64, len(o.Counts)-len(h.Counts))...)
+	}
+	for b, n := range o.Counts {
+		h.Counts[b] += n
+	}
+	if (h.N == 0) || (o.Min < h.Min) {
+		h.Min = o.Min
+	}
+	if o.Max > h.Max {
+		h.Max = o.Max
+	}
+	h.N += o.N
+	h.Sum += o.Sum
+}
+
+// Quantile returns an estimate of the q-quantile (0 <= q <= 1) of the values
+// recorded, or 0 for an empty histogram.
+func (h *Histogram) Quantile(q float64) uint64 {
+	if h.N == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.Min
+	}
+	if q >= 1 {
+		return h.Max
+	}
+	rank := uint64(math.Ceil(q * float64(h.N)))
+	var seen uint64
+	for b, n := range h.Counts {
+		seen += n
+		if seen >= rank {
+			v := histogramValue(b)
+			if v < h.Min {
+				v = h.Min
+			}
+			if v > h.Max {
+				v = h.Max
+			}
+			return v
+		}
+	}
+	return h.Max
+}
+
+// Mean returns the mean of the values recorded, or 0 for an empty histogram.
+func (h *Histogram) Mean() float64 {
+	if h.N == 0 {
+		return 0
+	}
+	return h.Sum / float64(h.N)
+}
+
+// Seconds returns the q-quantile of a histogram of nanosecond durations as
+// float64-seconds.
+func (h *Histogram) Seconds(q float64) float64 {
+	return float64(h.Quantile(q)) / 1e9
+}
@@ -0,0 +1,141 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHistogramBucket(t *testing.T) {
+	for v := uint64(0); v < 1<<20; v++ {
+		b := histogramBucket(v)
+		mid := histogramValue(b)
+		if mid != v && math.Abs(float64(mid)-float64(v)) > 0.016*float64(v) {
+			t.Fatalf("value %d: bucket %d midpoint %d is not within 1.6%%", v, b, mid)
+		}
+		if (v > 0) && (b < histogramBucket(v-1)) {
+			t.Fatalf("value %d: bucket %d is below the bucket of %d", v, b, v-1)
+		}
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	var h Histogram
+	for v := uint64(1); v <= 1000; v++ {
+		h.Record(v)
+	}
+	tests := []struct {
+		q    float64
+		want uint64
+	}{
+		{0, 1},
+		{0.01, 10},
+		{0.5, 500},
+		{0.99, 990},
+		{1, 1000},
+	}
+	for _, tt := range tests {
+		got := h.Quantile(tt.q)
+		if math.Abs(float64(got)-float64(tt.want)) > 0.016*float64(tt.want) {
+			t.Errorf("Quantile(%v) = %d, want %d within 1.6%%", tt.q, got, tt.want)
+		}
+	}
+	if h.N != 1000 || h.Min != 1 || h.Max != 1000 || h.Mean() != 500.5 {
+		t.Errorf("N, Min, Max, Mean = %d, %d, %d, %v", h.N, h.Min, h.Max, h.Mean())
+	}
+}
+
+func TestHistogramEmpty(t *testing.T) {
+	var h Histogram
+	h.RecordN(7, 0)
+	if h.N != 0 || h.Quantile(0.5) != 0 || h.Mean() != 0 {
+		t.Errorf("empty histogram: N %d, median %d, mean %v", h.N, h.Quantile(0.5), h.Mean())
+	}
+}
+
+func TestHistogramRecordN(t *testing.T) {
+	tests := []struct {
+		v, n uint64
+	}{
+		{0, 1},
+		{5, 3},
+		{1000, 17},
+		{123456789, 2},
+	}
+	for _, tt := range tests {
+		var one, many Histogram
+		for i := uint64(0); i < tt.n; i++ {
+			one.Record(tt.v)
+		}
+		many.RecordN(tt.v, tt.n)
+		if !equalHistograms(&one, &many) {
+			t.Errorf("RecordN(%d, %d) = %+v, want %+v", tt.v, tt.n, many, one)
+		}
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	tests := []struct {
+		a, b []uint64
+	}{
+		{nil, nil},
+		{nil, []uint64{3, 4}},
+		{[]uint64{3, 4}, nil},
+		{[]uint64{1, 2, 3}, []uint64{100000, 7}},
+		{[]uint64{100000}, []uint64{0}},
+	}
+	for _, tt := range tests {
+		var a, b, all Histogram
+		for _, v := range tt.a {
+			a.Record(v)
+			all.Record(v)
+		}
+		for _, v := range tt.b {
+			b.Record(v)
+			all.Record(v)
+		}
+		a.Merge(&b)
+		if !equalHistograms(&a, &all) {
+			t.Errorf("merge of %v and %v = %+v, want %+v", tt.a, tt.b, a, all)
+		}
+	}
+}
+
+// equalHistograms compares histograms, ignoring trailing zero counts.
+func equalHistograms(a, b *Histogram) bool {
+	if (a.N != b.N) || (a.Sum != b.Sum) || (a.Min != b.Min) || (a.Max != b.Max) {
+		return false
+	}
+	n := len(a.Counts)
+	if len(b.Counts) > n {
+		n = len(b.Counts)
+	}
+	for i := 0; i < n; i++ {
+		var x, y uint64
+		if i < len(a.Counts) {
+			x = a.Counts[i]
+		}
+		if i < len(b.Counts) {
+			y = b.Counts[i]
+		}
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
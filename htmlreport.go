@@ -0,0 +1,364 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/op/go-logging"
+)
+
+// The resolution of throughput over time, in seconds, and the maximum number
+// of points plotted.
+const (
+	reportTick   = 0.1
+	reportPoints = 300
+)
+
+// reportChart is a chart of an HTML report.
+type reportChart struct {
+	Title string
+	SVG   template.HTML
+}
+
+// reportPage is the content of an HTML report.
+type reportPage struct {
+	Dir     string
+	Summary [][2]string
+	Charts  []reportChart
+	Config  [][2]string
+}
+
+// reportData is the data gathered from the latency files for an HTML
+// report. Latencies are in ns. Blocks holds the largest TX count and size
+// seen for each block by any deliver client.
+type reportData struct {
+	files      int
+	txRows     uint64
+	blockRows  uint64
+	latency    Histogram
+	minLatency Histogram
+	maxLatency Histogram
+	delivered  []uint64
+	durations  []float64
+	blocks     map[int64]*latencyBlock
+}
+
+// deliver records TX delivered at a time (seconds).
+func (d *reportData) deliver(t float64, tx uint64) {
+	i := int(t / reportTick)
+	if i < 0 {
+		return
+	}
+	if i >= len(d.delivered) {
+		d.delivered = append(d.delivered, make([]uint64, i+1-len(d.delivered))...)
+	}
+	d.delivered[i] += tx
+}
+
+// block records what a deliver client saw of a block.
+func (d *reportData) block(number int64, tx, bytes uint64) {
+	if number < 0 {
+		return
+	}
+	b := d.blocks[number]
+	if b == nil {
+		b = &latencyBlock{Block: number}
+		d.blocks[number] = b
+	}
+	if tx > b.NumTX {
+		b.NumTX = tx
+	}
+	if bytes > b.Bytes {
+		b.Bytes = bytes
+	}
+}
+
+// The HTML report generator is called as
+//
+//	obx report [-o <file>] [-prefix <prefix>] <latency directory>
+//
+// and renders the latency files in the directory, written by a run with
+// -latencyDir, as a self-contained HTML page with SVG charts.
+func htmlReport() {
+
+	logger = logging.MustGetLogger("report")
+
+	var output, prefix, logLevel string
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+
+	flags.StringVar(&output, "o", "",
+		"The HTML file to create; Default report.html in the latency directory")
+
+	flags.StringVar(&prefix, "prefix", "",
+		"Only report the latency files with this prefix; Default all latency files")
+
+	flags.StringVar(&logLevel, "logLevel", "info",
+		"The logging level; Default 'info'")
+
+	flags.Parse(os.Args[2:])
+
+	initLogging(logLevel)
+
+	if flags.NArg() != 1 {
+		logger.Fatalf("Usage: obx report [flags] <latency directory>")
+	}
+	dir := flags.Arg(0)
+	if output == "" {
+		output = filepath.Join(dir, "report.html")
+	}
+
+	files, err := findLatencyFiles(dir, prefix)
+	if err != nil {
+		logger.Fatalf("Error finding latency files in %s: %s", dir, err)
+	}
+	if len(files) == 0 {
+		logger.Fatalf("No latency files found in %s", dir)
+	}
+
+	// Gather the data. The blocks of files of all latencies are
+	// reconstructed from the TX rows.
+
+	d := &reportData{blocks: make(map[int64]*latencyBlock)}
+	for _, f := range files {
+		var duration float64
+		fileBlocks := make(map[int64]*latencyBlock)
+		err := f.scan(
+			func(t *latencyTx) {
+				d.txRows++
+				d.latency.Record(uint64(t.Latency * 1e9))
+				d.deliver(t.Tdelivered, 1)
+				if t.Tdelivered > duration {
+					duration = t.Tdelivered
+				}
+				if t.Block >= 0 {
+					b := fileBlocks[t.Block]
+					if b == nil {
+						b = &latencyBlock{Block: t.Block}
+						fileBlocks[t.Block] = b
+					}
+					b.NumTX++
+					b.Bytes += t.Size
+				}
+			},
+			func(b *latencyBlock) {
+				d.blockRows++
+				d.minLatency.Record(uint64(b.MinLatency * 1e9))
+				d.maxLatency.Record(uint64(b.MaxLatency * 1e9))
+				d.deliver(b.Tdelivered, b.NumTX)
+				if b.Tdelivered > duration {
+					duration = b.Tdelivered
+				}
+				d.block(b.Block, b.NumTX, b.Bytes)
+			})
+		if err != nil {
+			logger.Fatalf("Error reading latency file: %s", err)
+		}
+		for _, b := range fileBlocks {
+			d.block(b.Block, b.NumTX, b.Bytes)
+		}
+		d.files++
+		d.durations = append(d.durations, duration)
+	}
+
+	page := &reportPage{Dir: dir}
+	page.summary(d)
+	page.charts(d)
+	page.Config = readReportConfig(filepath.Join(dir, files[0].Prefix+".config.json"))
+
+	f, err := os.Create(output)
+	if err != nil {
+		logger.Fatalf("Error creating %s: %s", output, err)
+	}
+	defer f.Close()
+	if err = reportTemplate.Execute(f, page); err != nil {
+		logger.Fatalf("Error writing %s: %s", output, err)
+	}
+	logger.Infof("Wrote the report of %d latency files to %s", d.files, output)
+}
+
+// summary sets the summary table of a report.
+func (p *reportPage) summary(d *reportData) {
+	row := func(name, value string) {
+		p.Summary = append(p.Summary, [2]string{name, value})
+	}
+	seconds := func(h *Histogram, q float64) string {
+		return strconv.FormatFloat(h.Seconds(q), 'f', 6, 64)
+	}
+	row("Latency Files", strconv.Itoa(d.files))
+	row("Blocks", commafy(int64(len(d.blocks))))
+	if d.txRows != 0 {
+		row("TX Delivered", commafy(int64(d.txRows)))
+		row("Median Latency (s)", seconds(&d.latency, .5))
+		row("99% Latency (s)", seconds(&d.latency, .99))
+		row("Worst Latency (s)", seconds(&d.latency, 1))
+	}
+	if d.blockRows != 0 {
+		row("Blocks Delivered", commafy(int64(d.blockRows)))
+		row("Median Block Max. Latency (s)", seconds(&d.maxLatency, .5))
+		row("99% Block Max. Latency (s)", seconds(&d.maxLatency, .99))
+	}
+	var longest float64
+	for _, t := range d.durations {
+		if t > longest {
+			longest = t
+		}
+	}
+	row("Longest Deliver Client (s)", strconv.FormatFloat(longest, 'f', 3, 64))
+}
+
+// charts sets the charts of a report.
+func (p *reportPage) charts(d *reportData) {
+
+	chart := func(title string, svg template.HTML) {
+		p.Charts = append(p.Charts, reportChart{title, svg})
+	}
+	cdf := func(name string, h *Histogram) svgSeries {
+		s := svgSeries{Name: name}
+		for i := 0; i <= 100; i++ {
+			s.X = append(s.X, h.Seconds(float64(i)/100))
+			s.Y = append(s.Y, float64(i)/100)
+		}
+		return s
+	}
+
+	var latency []svgSeries
+	if d.latency.N != 0 {
+		latency = append(latency, cdf("TX", &d.latency))
+	}
+	if d.minLatency.N != 0 {
+		latency = append(latency, cdf("Block Minimum", &d.minLatency),
+			cdf("Block Maximum", &d.maxLatency))
+	}
+	chart("Latency", svgLineChart("Latency CDF", "Latency (seconds)",
+		"Fraction of TX", latency))
+
+	// Throughput is rebinned so that at most reportPoints are plotted.
+
+	per := (len(d.delivered) + reportPoints - 1) / reportPoints
+	if per == 0 {
+		per = 1
+	}
+	throughput := svgSeries{Name: "Delivered"}
+	for i := 0; i < len(d.delivered); i += per {
+		var tx uint64
+		for j := i; (j < i+per) && (j < len(d.delivered)); j++ {
+			tx += d.delivered[j]
+		}
+		throughput.X = append(throughput.X, float64(i)*reportTick)
+		throughput.Y = append(throughput.Y, float64(tx)/(float64(per)*reportTick))
+	}
+	chart("Throughput", svgLineChart("Delivery Throughput (All Deliver Clients)",
+		"Time (seconds)", "TX per second", []svgSeries{throughput}))
+
+	chart("Deliver Clients", svgBarChart("Deliver Client Durations",
+		"Duration (seconds)", "Clients", d.durations, 20))
+
+	numbers := make([]int64, 0, len(d.blocks))
+	for n := range d.blocks {
+		numbers = append(numbers, n)
+	}
+	sort.Sort(int64Slice(numbers))
+	var txPerBlock, bytesPerBlock []float64
+	for _, n := range numbers {
+		txPerBlock = append(txPerBlock, float64(d.blocks[n].NumTX))
+		bytesPerBlock = append(bytesPerBlock, float64(d.blocks[n].Bytes))
+	}
+	chart("Blocks", svgBarChart("TX per Block", "TX", "Blocks", txPerBlock, 20))
+	chart("Block Sizes", svgBarChart("Bytes per Block", "Bytes", "Blocks",
+		bytesPerBlock, 20))
+}
+
+// int64Slice sorts int64s in increasing order.
+type int64Slice []int64
+
+func (s int64Slice) Len() int           { return len(s) }
+func (s int64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s int64Slice) Less(i, j int) bool { return s[i] < s[j] }
+
+// readReportConfig reads the configuration saved by the control process in
+// the latency directory, if any, as a table of names and values.
+func readReportConfig(path string) (rows [][2]string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		logger.Infof("No configuration found: %s", err)
+		return nil
+	}
+	var cfg map[string]interface{}
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		logger.Errorf("Error reading the configuration in %s: %s", path, err)
+		return nil
+	}
+	names := make([]string, 0, len(cfg))
+	for name := range cfg {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var value string
+		switch v := cfg[name].(type) {
+		case string:
+			value = v
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			out, _ := json.Marshal(v)
+			value = string(out)
+			if len(value) > 200 {
+				value = value[:200] + "..."
+			}
+		}
+		rows = append(rows, [2]string{name, value})
+	}
+	return
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>obx Report: {{.Dir}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: left; font-size: 13px; }
+td { font-family: monospace; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>obx Report: {{.Dir}}</h1>
+<h2>Summary</h2>
+<table>
+{{range .Summary}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{range .Charts}}<h2>{{.Title}}</h2>
+<div>{{.SVG}}</div>
+{{end}}<h2>Configuration</h2>
+{{if .Config}}<table>
+{{range .Config}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{else}}<p>The configuration was not recorded with the latency files.</p>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,91 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// inFlightWindow tracks the TX a broadcast client has sent that are not yet
+// acknowledged. If -maxInFlight is set, the window is bounded, and the
+// client blocks before sending while the window is full, as a well-behaved
+// SDK client would. The window is shared by the sending goroutine and the
+// goroutine receiving the ACKs.
+type inFlightWindow struct {
+	slots chan struct{} // Nil if unbounded
+	sent  uint64        // Only updated by the sender
+	acked uint64        // Updated atomically
+}
+
+// newInFlightWindow creates a window of a maximum size, or an unbounded
+// window if the maximum is 0.
+func newInFlightWindow(max int) *inFlightWindow {
+	w := &inFlightWindow{}
+	if max != 0 {
+		w.slots = make(chan struct{}, max)
+	}
+	return w
+}
+
+// reserve reserves a place in the window for a TX about to be sent. It
+// returns true if the sender had to wait for the window to open.
+func (w *inFlightWindow) reserve() (blocked bool) {
+	if w.slots == nil {
+		return false
+	}
+	select {
+	case w.slots <- struct{}{}:
+	default:
+		w.slots <- struct{}{}
+		blocked = true
+	}
+	return
+}
+
+// send records a TX sent, returning the # of TX in flight, including it.
+func (w *inFlightWindow) send() uint64 {
+	w.sent++
+	return w.sent - atomic.LoadUint64(&w.acked)
+}
+
+// ack records a TX acknowledged, opening the window.
+func (w *inFlightWindow) ack() {
+	atomic.AddUint64(&w.acked, 1)
+	if w.slots != nil {
+		<-w.slots
+	}
+}
+
+// reportInFlight prints the distribution of the # of TX in flight, sampled
+// by each broadcast client as it sent each TX, and how often the clients
+// blocked on a bounded window.
+func reportInFlight(cfg *Config, h *Histogram, blocked uint64) {
+	fmt.Printf("In-Flight TX       :       Best     Median        90%%        95%%        99%%      Worst\n")
+	fmt.Printf("    Per Client     : %10d %10d %10d %10d %10d %10d\n",
+		h.Quantile(0), h.Quantile(.5), h.Quantile(.9), h.Quantile(.95),
+		h.Quantile(.99), h.Quantile(1))
+	if cfg.MaxInFlight != 0 {
+		var percent float64
+		if h.N != 0 {
+			percent = 100 * float64(blocked) / float64(h.N)
+		}
+		fmt.Printf("    Max. In-Flight : %10d\n", cfg.MaxInFlight)
+		fmt.Printf("    Blocked Sends  : %10s (%.1f%% of TX)\n",
+			commafy(int64(blocked)), percent)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInFlightWindow(t *testing.T) {
+
+	// Each step either sends (s) or acknowledges (a) a TX; Sends report the
+	// # of TX in flight.
+
+	tests := []struct {
+		max      int
+		steps    string
+		inFlight []uint64
+	}{
+		{0, "sssaas", []uint64{1, 2, 3, 2}},
+		{2, "ssasas", []uint64{1, 2, 2, 2}},
+		{1, "sasasa", []uint64{1, 1, 1}},
+	}
+	for _, tt := range tests {
+		w := newInFlightWindow(tt.max)
+		var inFlight []uint64
+		for _, step := range tt.steps {
+			if step == 's' {
+				if w.reserve() {
+					t.Errorf("max %d, %s: reserve() blocked", tt.max, tt.steps)
+				}
+				inFlight = append(inFlight, w.send())
+			} else {
+				w.ack()
+			}
+		}
+		if len(inFlight) != len(tt.inFlight) {
+			t.Errorf("max %d, %s: in flight %v, want %v", tt.max, tt.steps, inFlight, tt.inFlight)
+			continue
+		}
+		for i := range inFlight {
+			if inFlight[i] != tt.inFlight[i] {
+				t.Errorf("max %d, %s: in flight %v, want %v", tt.max, tt.steps, inFlight, tt.inFlight)
+				break
+			}
+		}
+	}
+}
+
+func TestInFlightWindowBlocks(t *testing.T) {
+	w := newInFlightWindow(1)
+	w.reserve()
+	w.send()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		w.ack()
+	}()
+	if !w.reserve() {
+		t.Errorf("reserve() of a full window did not block")
+	}
+	if n := w.send(); n != 1 {
+		t.Errorf("send() = %d TX in flight, want 1", n)
+	}
+}
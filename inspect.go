@@ -0,0 +1,300 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/orderer/common/bootstrap/provisional"
+	"github.com/hyperledger/fabric/protos/common"
+
+	"github.com/op/go-logging"
+)
+
+// inspectTx is an obx transaction found by obx inspect.
+type inspectTx struct {
+	Index int // Index of the TX in its block
+	Size  int // Payload size
+	TxHeader
+}
+
+// inspectBlock is a block found by obx inspect.
+type inspectBlock struct {
+	Number          uint64
+	Transactions    int         // # of TX in the block
+	ObxTransactions int         // # of obx TX in the block
+	Bytes           int         // Size of the marshaled block
+	PreviousHash    string      // Hex
+	DataHash        string      // Hex
+	MetadataSizes   []int       // Size of each metadata entry
+	Headers         []inspectTx `json:",omitempty"`
+}
+
+// inspectOrigin summarizes the obx transactions of one broadcast client on
+// one channel found by obx inspect.
+type inspectOrigin struct {
+	Server        uint16
+	Channel       uint16
+	Client        uint16
+	Count         uint64 // # of TX found
+	Bytes         uint64 // Payload bytes found
+	FirstSequence uint32
+	LastSequence  uint32
+	Missing       uint64 // # of sequence numbers missing from the range
+	FirstBlock    uint64
+	LastBlock     uint64
+}
+
+// inspection is the result of obx inspect. The blocks are only kept for JSON
+// output; Text output prints each block as it is found.
+type inspection struct {
+	Server          string
+	ChainID         string
+	Blocks          []inspectBlock
+	Transactions    uint64 // # of TX found
+	ObxTransactions uint64 // # of obx TX found
+	Bytes           uint64 // Total size of the marshaled blocks
+	Origins         []*inspectOrigin
+
+	numBlocks  uint64 // # of blocks found
+	firstBlock uint64
+	lastBlock  uint64
+}
+
+// byOrigin sorts origins by server, then channel, then client.
+type byOrigin []*inspectOrigin
+
+func (b byOrigin) Len() int      { return len(b) }
+func (b byOrigin) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byOrigin) Less(i, j int) bool {
+	if b[i].Server != b[j].Server {
+		return b[i].Server < b[j].Server
+	}
+	if b[i].Channel != b[j].Channel {
+		return b[i].Channel < b[j].Channel
+	}
+	return b[i].Client < b[j].Client
+}
+
+// The ledger inspector is called as
+//
+//	obx inspect -server <address> [-channel <id>] [-from N] [-to M] [-json]
+//
+// and prints the blocks from N (default 0) through M (default the newest
+// block) of a chain, followed by a summary of the obx transactions found.
+func inspect() {
+
+	logger = logging.MustGetLogger("inspect")
+
+	var server, chainID, compression, logLevel string
+	var from uint64
+	var to int64
+	var asJSON, transactions bool
+
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+
+	flags.StringVar(&server, "server", "",
+		"The IP:PORT of the deliver server to inspect; Required")
+
+	flags.StringVar(&chainID, "channel", provisional.TestChainID,
+		"The ID of the channel (chain) to inspect; Default "+provisional.TestChainID)
+
+	flags.Uint64Var(&from, "from", 0,
+		"The first block to inspect; Default 0")
+
+	flags.Int64Var(&to, "to", -1,
+		"The last block to inspect; Default is the newest block")
+
+	flags.BoolVar(&transactions, "transactions", false,
+		"Set to true to list the header of every obx transaction")
+
+	flags.BoolVar(&asJSON, "json", false,
+		"Set to true to print the inspection as JSON")
+
+	flags.StringVar(&compression, "compression", NoCompression,
+		"The gRPC transport compression: none or gzip; Default none")
+
+	flags.StringVar(&logLevel, "logLevel", "info",
+		"The logging level; Default 'info'")
+
+	flags.Parse(os.Args[2:])
+
+	initLogging(logLevel)
+
+	requireNonEmpty("server", server)
+	requireNonEmpty("channel", chainID)
+	if (to >= 0) && (uint64(to) < from) {
+		bogus("to", "at least the value of -from")
+	}
+	if (compression != NoCompression) && (compression != GzipCompression) {
+		bogus("compression", "either none or gzip")
+	}
+
+	stop := seekNewest()
+	if to >= 0 {
+		stop = seekSpecified(uint64(to))
+	}
+
+	// Scan the blocks, keeping transaction headers only if they are listed.
+	// Blocks are printed as they are found, unless the output is JSON.
+
+	in := &inspection{Server: server, ChainID: chainID}
+	origins := make(map[origin]*inspectOrigin)
+
+	err := scanLedger(&Config{Compression: compression}, server, chainID,
+		seekSpecified(from), stop,
+		func(block *common.Block) error {
+			messages, err := blockMessages(block)
+			if err != nil {
+				return err
+			}
+			b := inspectBlock{
+				Number:       block.Header.Number,
+				Transactions: len(messages),
+				Bytes:        proto.Size(block),
+				PreviousHash: hex.EncodeToString(block.Header.PreviousHash),
+				DataHash:     hex.EncodeToString(block.Header.DataHash),
+			}
+			if block.Metadata != nil {
+				for _, m := range block.Metadata.Metadata {
+					b.MetadataSizes = append(b.MetadataSizes, len(m))
+				}
+			}
+			for i, message := range messages {
+				if !isTx(message) {
+					continue
+				}
+				t := inspectTx{Index: i, Size: len(message)}
+				t.Get(message)
+				b.ObxTransactions++
+				if transactions {
+					b.Headers = append(b.Headers, t)
+				}
+				o := origins[t.origin()]
+				if o == nil {
+					o = &inspectOrigin{
+						Server:        t.Server,
+						Channel:       t.Channel,
+						Client:        t.Client,
+						FirstSequence: t.Sequence,
+						LastSequence:  t.Sequence,
+						FirstBlock:    b.Number,
+					}
+					origins[t.origin()] = o
+				}
+				o.Count++
+				o.Bytes += uint64(t.Size)
+				if t.Sequence < o.FirstSequence {
+					o.FirstSequence = t.Sequence
+				}
+				if t.Sequence > o.LastSequence {
+					o.LastSequence = t.Sequence
+				}
+				o.LastBlock = b.Number
+			}
+			if asJSON {
+				in.Blocks = append(in.Blocks, b)
+			} else {
+				b.report()
+			}
+			if in.numBlocks == 0 {
+				in.firstBlock = b.Number
+			}
+			in.numBlocks++
+			in.lastBlock = b.Number
+			in.Transactions += uint64(b.Transactions)
+			in.ObxTransactions += uint64(b.ObxTransactions)
+			in.Bytes += uint64(b.Bytes)
+			return nil
+		})
+	if err != nil {
+		logger.Fatalf("Inspection of %s failed: %s", server, err)
+	}
+
+	for _, o := range origins {
+		span := uint64(o.LastSequence-o.FirstSequence) + 1
+		if o.Count < span {
+			o.Missing = span - o.Count
+		}
+		in.Origins = append(in.Origins, o)
+	}
+	sort.Sort(byOrigin(in.Origins))
+
+	if asJSON {
+		out, err := json.MarshalIndent(in, "", "  ")
+		if err != nil {
+			logger.Fatalf("Error encoding the inspection as JSON: %s", err)
+		}
+		fmt.Printf("%s\n", out)
+	} else {
+		in.report()
+	}
+}
+
+// report prints a block as text.
+func (b *inspectBlock) report() {
+	fmt.Printf("Block %d: %d TX (%d obx), %s bytes\n",
+		b.Number, b.Transactions, b.ObxTransactions, commafy(int64(b.Bytes)))
+	fmt.Printf("    Previous Hash : %s\n", b.PreviousHash)
+	fmt.Printf("    Data Hash     : %s\n", b.DataHash)
+	fmt.Printf("    Metadata Sizes: %v\n", b.MetadataSizes)
+	for _, t := range b.Headers {
+		fmt.Printf("    TX %4d       : Server %d, Channel %d, Client %d, "+
+			"Sequence %d, %d bytes, Broadcast @ %.9f\n",
+			t.Index, t.Server, t.Channel, t.Client, t.Sequence, t.Size,
+			float64(t.Tbroadcast)/1e9)
+	}
+}
+
+// report prints the summary of an inspection as text, following the blocks.
+func (in *inspection) report() {
+
+	fmt.Printf("****************************************************************************\n")
+	fmt.Printf("Ledger Summary\n")
+	fmt.Printf("    Server          : %s\n", in.Server)
+	fmt.Printf("    Channel         : %s\n", in.ChainID)
+	if in.numBlocks != 0 {
+		fmt.Printf("    Blocks          : %s (%d - %d)\n",
+			commafy(int64(in.numBlocks)), in.firstBlock, in.lastBlock)
+	} else {
+		fmt.Printf("    Blocks          : 0\n")
+	}
+	fmt.Printf("    Transactions    : %s\n", commafy(int64(in.Transactions)))
+	fmt.Printf("    obx Transactions: %s\n", commafy(int64(in.ObxTransactions)))
+	fmt.Printf("    Bytes           : %s\n", commafy(int64(in.Bytes)))
+
+	if len(in.Origins) == 0 {
+		return
+	}
+	fmt.Printf("****************************************************************************\n")
+	fmt.Printf("obx Transaction Origins\n")
+	fmt.Printf("    %6s %7s %6s %12s %23s %10s %21s\n",
+		"Server", "Channel", "Client", "TX", "Sequences", "Missing", "Blocks")
+	for _, o := range in.Origins {
+		fmt.Printf("    %6d %7d %6d %12s %11d - %-9d %10s %10d - %-8d\n",
+			o.Server, o.Channel, o.Client, commafy(int64(o.Count)),
+			o.FirstSequence, o.LastSequence, commafy(int64(o.Missing)),
+			o.FirstBlock, o.LastBlock)
+	}
+}
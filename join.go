@@ -0,0 +1,293 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/rpc"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Normally every deliver client seeks before the start of the run, and
+// follows the tip of the ledger. With -lateJoiners the last deliver clients
+// of each server and channel instead join while broadcast continues, seeking
+// from the start block once they join, and catch up with the tip. A late
+// joiner has caught up once it delivers a TX broadcast after it joined,
+// since it has then delivered every block that was on the ledger when it
+// joined.
+
+// Join time models (-joinAt)
+const (
+	OffsetJoin   = "offsets"  // Fixed offsets from the start of the run
+	UniformJoin  = "uniform"  // Spread uniformly over a period
+	ProgressJoin = "progress" // At fractions of the broadcast progress
+)
+
+// parseJoinAt parses the join time model of the late joiners, which is one
+// of offsets:D0,D1,..., uniform:D or progress:F0,F1,... with durations D or
+// fractions F of the TX of every broadcast client. Offsets and fractions are
+// assigned to the late joiners round-robin.
+func parseJoinAt(c *Config, val string) {
+
+	why := "offsets:D0,D1,..., uniform:D or progress:F0,F1,..."
+
+	kv := strings.SplitN(val, ":", 2)
+	if len(kv) != 2 {
+		bogus("joinAt", why)
+	}
+	args := strings.Split(kv[1], ",")
+	c.JoinMode = kv[0]
+	switch c.JoinMode {
+	case OffsetJoin, UniformJoin:
+		if (c.JoinMode == UniformJoin) && (len(args) != 1) {
+			bogus("joinAt", why)
+		}
+		for _, a := range args {
+			d, err := time.ParseDuration(a)
+			if (err != nil) || (d < 0) {
+				bogus("joinAt", why)
+			}
+			c.JoinTimes = append(c.JoinTimes, d)
+		}
+	case ProgressJoin:
+		if !c.Broadcast {
+			bogus("joinAt", "offsets or uniform for runs that do not broadcast")
+		}
+		for _, a := range args {
+			f, err := strconv.ParseFloat(a, 64)
+			if (err != nil) || (f < 0) || (f > 1) {
+				bogus("joinAt", why+" with 0 <= F <= 1")
+			}
+			c.JoinFractions = append(c.JoinFractions, f)
+		}
+	default:
+		bogus("joinAt", why)
+	}
+}
+
+// joinIndex returns the index of a deliver client among the late joiners of
+// its server and channel, or false if it is not a late joiner.
+func (c *Config) joinIndex(client *Client) (int, bool) {
+	first := c.Dclients - c.LateJoiners
+	if client.Client < first {
+		return 0, false
+	}
+	return client.Client - first, true
+}
+
+// joinOffset returns the join time of a late joiner, for the offset and
+// uniform models.
+func (c *Config) joinOffset(index int) time.Duration {
+	if c.JoinMode == UniformJoin {
+		return time.Duration(index+1) * c.JoinTimes[0] / time.Duration(c.LateJoiners)
+	}
+	return c.JoinTimes[index%len(c.JoinTimes)]
+}
+
+// progressThresholds returns the # of TX a broadcast client has sent when it
+// passes each of the join fractions.
+func (c *Config) progressThresholds(w *Workload) []int {
+	thresholds := make([]int, len(c.JoinFractions))
+	for i, f := range c.JoinFractions {
+		thresholds[i] = int(math.Ceil(f * float64(w.Transactions)))
+	}
+	return thresholds
+}
+
+// broadcastProgress returns the function a broadcast client calls with the #
+// of TX it has sent, to report passing each join fraction, or nil if the late
+// joiners do not join on progress. The reports are asynchronous, so as not to
+// delay the broadcast.
+func broadcastProgress(cfg *Config, client *Client, rpcClient *rpc.Client,
+	w *Workload) func(sent int) {
+
+	if len(cfg.JoinFractions) == 0 {
+		return nil
+	}
+	thresholds := cfg.progressThresholds(w)
+	passed := make([]bool, len(thresholds))
+	return func(sent int) {
+		for i, threshold := range thresholds {
+			if !passed[i] && (sent >= threshold) {
+				passed[i] = true
+				rpcClient.Go("Control.Progress",
+					&Progress{Client: *client, Index: i}, new(int), nil)
+			}
+		}
+	}
+}
+
+// Progress is reported by a broadcast client as it passes a join fraction.
+type Progress struct {
+	Client
+	Index int // Index of the fraction passed
+}
+
+// CatchUp is the catch-up of a late joiner. Times are in ns since the start
+// of the run. Tip is the time the joiner caught up with the tip of the
+// ledger, or the end of its delivery if broadcast ended before it caught up.
+type CatchUp struct {
+	Client
+	Join    uint64
+	Tip     uint64
+	Blocks  uint64 // Blocks holding obx TX delivered before catching up
+	TX      uint64 // obx TX delivered before catching up
+	Reached bool   // Did the joiner catch up before broadcast ended?
+}
+
+// contains returns true if a timestamp falls within the catch-up.
+func (c *CatchUp) contains(t uint64) bool {
+	return (t >= c.Join) && (t < c.Tip)
+}
+
+// Join is an RPC callback from late joiners, once they are past the Start
+// barrier, which returns when a joiner is to join the run.
+func (c *Control) Join(client *Client, ignore *int) error {
+	index, _ := c.cfg.joinIndex(client)
+	if c.cfg.JoinMode == ProgressJoin {
+		c.progressWG[index%len(c.progressWG)].Wait()
+	} else {
+		time.Sleep(c.stats.Tstart.Add(c.cfg.joinOffset(index)).Sub(time.Now()))
+	}
+	logger.Infof("Deliver client %v joins", *client)
+	return nil
+}
+
+// Progress is an RPC callback from broadcast clients as they pass each join
+// fraction.
+func (c *Control) Progress(progress *Progress, ignore *int) error {
+	c.progressWG[progress.Index].Done()
+	return nil
+}
+
+// CaughtUp is an RPC callback from late joiners once they catch up.
+func (c *Control) CaughtUp(catchUp *CatchUp, ignore *int) error {
+	logger.Infof("Deliver client %v caught up in %.3f seconds",
+		catchUp.Client, float64(catchUp.Tip-catchUp.Join)/1e9)
+	c.mutex.Lock()
+	c.stats.CatchUps = append(c.stats.CatchUps, *catchUp)
+	c.mutex.Unlock()
+	c.joinWG.Done()
+	return nil
+}
+
+// CatchUps is an RPC callback from the other deliver clients, returning the
+// catch-ups of the late joiners once all of them have caught up.
+func (c *Control) CatchUps(client *Client, reply *[]CatchUp) error {
+	c.joinWG.Wait()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	*reply = c.stats.CatchUps
+	return nil
+}
+
+// catchUpTracker tracks the catch-up of a late joiner as its TX are
+// delivered, and reports it to the control process.
+type catchUpTracker struct {
+	catchUp   CatchUp
+	lastBlock uint64
+	rpcClient *rpc.Client
+	reported  bool
+}
+
+// joinLate waits until a late joiner is to join, and returns its tracker.
+func joinLate(client *Client, rpcClient *rpc.Client, clock *Clock) *catchUpTracker {
+	var ignore int
+	err := rpcClient.Call("Control.Join", client, &ignore)
+	if err != nil {
+		logger.Fatalf("Deliver client %v: RPC Control.Join failed: %s",
+			client, err)
+	}
+	return &catchUpTracker{
+		catchUp:   CatchUp{Client: *client, Join: clock.since()},
+		lastBlock: math.MaxUint64,
+		rpcClient: rpcClient,
+	}
+}
+
+// add tracks a TX delivered.
+func (c *catchUpTracker) add(r *txRecord) {
+	if c.reported {
+		return
+	}
+	if r.Tbroadcast >= c.catchUp.Join {
+		c.catchUp.Reached = true
+		c.finish(r.Tdelivered)
+		return
+	}
+	if r.Block != c.lastBlock {
+		c.catchUp.Blocks++
+		c.lastBlock = r.Block
+	}
+	c.catchUp.TX++
+}
+
+// finish reports the catch-up at a given time, unless it has been reported.
+func (c *catchUpTracker) finish(t uint64) {
+	if c.reported {
+		return
+	}
+	c.reported = true
+	c.catchUp.Tip = t
+	var ignore int
+	err := c.rpcClient.Call("Control.CaughtUp", &c.catchUp, &ignore)
+	if err != nil {
+		logger.Fatalf("Deliver client %v: RPC Control.CaughtUp failed: %s",
+			c.catchUp.Client, err)
+	}
+}
+
+// reportCatchUps prints the catch-up of each late joiner, the latency of the
+// TX delivered to the late joiners, which are left out of the other latency
+// statistics, and the latency of the TX delivered to the other deliver
+// clients, split by whether the TX were broadcast while any late joiner was
+// catching up. This split stands in for a split of the broadcast (ACK)
+// latency, which broadcast clients only aggregate by phase, without the
+// broadcast times needed to split it. The delivery latency covers the same
+// TX from broadcast through ordering, so it shows the effect of the catch-up
+// reads on the orderer as broadcast continues.
+func (s *Stats) reportCatchUps(cfg *Config) {
+
+	fmt.Printf("****************************************************************************\n")
+	fmt.Printf("Late Joiners (%d per server and channel, joining at %s)\n",
+		cfg.LateJoiners, cfg.JoinAt)
+	fmt.Printf("    %-15s %10s %14s %12s %14s\n",
+		"Client", "Join Sec.", "Catch-Up Sec.", "Blocks", "TX")
+	for _, c := range s.CatchUps {
+		note := ""
+		if !c.Reached {
+			note = " (Broadcast ended first)"
+		}
+		fmt.Printf("    %-15s %10.3f %14.3f %12s %14s%s\n",
+			fmt.Sprintf("%d.%d.%d", c.Server, c.Channel, c.Client.Client),
+			float64(c.Join)/1e9, float64(c.Tip-c.Join)/1e9,
+			commafy(int64(c.Blocks)), commafy(int64(c.TX)), note)
+	}
+	if s.JoinerLatency.N != 0 {
+		printLatency("Joiners", &s.JoinerLatency)
+	}
+	if s.DuringCatchUp.N+s.OutsideCatchUp.N != 0 {
+		fmt.Printf("Latency of the TX delivered to the other deliver clients, as broadcast\n")
+		fmt.Printf("while late joiners caught up, or otherwise (Broadcast-to-delivery\n")
+		fmt.Printf("latency stands in for the broadcast ACK latency, which is not split)\n")
+		printLatency("Catch-Up", &s.DuringCatchUp)
+		printLatency("Otherwise", &s.OutsideCatchUp)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseJoinAt(t *testing.T) {
+	tests := []struct {
+		val       string
+		mode      string
+		times     []time.Duration
+		fractions []float64
+	}{
+		{"offsets:1s", OffsetJoin, []time.Duration{time.Second}, nil},
+		{"offsets:0s,500ms,2m", OffsetJoin,
+			[]time.Duration{0, 500 * time.Millisecond, 2 * time.Minute}, nil},
+		{"uniform:10s", UniformJoin, []time.Duration{10 * time.Second}, nil},
+		{"progress:0.5", ProgressJoin, nil, []float64{0.5}},
+		{"progress:0,0.25,1", ProgressJoin, nil, []float64{0, 0.25, 1}},
+	}
+	for _, tt := range tests {
+		c := &Config{Broadcast: true}
+		parseJoinAt(c, tt.val)
+		if (c.JoinMode != tt.mode) || !reflect.DeepEqual(c.JoinTimes, tt.times) ||
+			!reflect.DeepEqual(c.JoinFractions, tt.fractions) {
+			t.Errorf("parseJoinAt(%q) = %s, %v, %v; want %s, %v, %v", tt.val,
+				c.JoinMode, c.JoinTimes, c.JoinFractions, tt.mode, tt.times, tt.fractions)
+		}
+	}
+}
+
+func TestJoinOffset(t *testing.T) {
+	tests := []struct {
+		val   string
+		want  []time.Duration // Of each late joiner
+		index []int           // Of each deliver client, or -1
+	}{
+		{"offsets:1s,3s", []time.Duration{time.Second, 3 * time.Second, time.Second},
+			[]int{-1, 0, 1, 2}},
+		{"uniform:9s", []time.Duration{3 * time.Second, 6 * time.Second, 9 * time.Second},
+			[]int{-1, 0, 1, 2}},
+	}
+	for _, tt := range tests {
+		c := &Config{Dclients: 4, LateJoiners: 3}
+		parseJoinAt(c, tt.val)
+		for client, want := range tt.index {
+			index, late := c.joinIndex(&Client{Client: client})
+			if (late != (want >= 0)) || (late && (index != want)) {
+				t.Errorf("%s: joinIndex(%d) = %d, %v; want %d", tt.val, client, index, late, want)
+			}
+		}
+		for index, want := range tt.want {
+			if got := c.joinOffset(index); got != want {
+				t.Errorf("%s: joinOffset(%d) = %s, want %s", tt.val, index, got, want)
+			}
+		}
+	}
+}
+
+func TestProgressThresholds(t *testing.T) {
+	c := &Config{Broadcast: true}
+	parseJoinAt(c, "progress:0,0.1,0.5,1")
+	got := c.progressThresholds(&Workload{Transactions: 15})
+	if want := []int{0, 2, 8, 15}; !reflect.DeepEqual(got, want) {
+		t.Errorf("progressThresholds() = %v, want %v", got, want)
+	}
+}
+
+func TestCatchUpTracker(t *testing.T) {
+
+	// The tracker is only asked to report once the joiner catches up.
+
+	c := &catchUpTracker{catchUp: CatchUp{Join: 100}, lastBlock: ^uint64(0)}
+	for _, r := range []txRecord{
+		{TxHeader: TxHeader{Tbroadcast: 10}, Block: 1},
+		{TxHeader: TxHeader{Tbroadcast: 20}, Block: 1},
+		{TxHeader: TxHeader{Tbroadcast: 30}, Block: 2},
+	} {
+		c.add(&r)
+	}
+	if (c.catchUp.Blocks != 2) || (c.catchUp.TX != 3) || c.catchUp.Reached || c.reported {
+		t.Errorf("catch-up %+v", c.catchUp)
+	}
+
+	cu := CatchUp{Join: 100, Tip: 200}
+	for _, tt := range []struct {
+		t    uint64
+		want bool
+	}{{99, false}, {100, true}, {199, true}, {200, false}} {
+		if got := cu.contains(tt.t); got != tt.want {
+			t.Errorf("contains(%d) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// latencyTx is a row of a latency file of all TX latencies. Times are in
+// float64-seconds. Columns missing from older files are zero, except that
+// Block and Index are -1.
+type latencyTx struct {
+	Server     int
+	Channel    int
+	Client     int
+	Sequence   uint64
+	Tbroadcast float64
+	Tdelivered float64
+	Latency    float64
+	Size       uint64
+	Block      int64
+	Index      int64
+}
+
+// latencyBlock is a row of a block latency file. Times are in
+// float64-seconds. Columns missing from older files are zero. Older files
+// numbered blocks from 0 rather than recording the actual block numbers.
+type latencyBlock struct {
+	Block      int64
+	NumTX      uint64
+	Bytes      uint64
+	Tfirst     float64
+	Tdelivered float64
+	Formation  float64
+	Gap        float64
+	MinLatency float64
+	MaxLatency float64
+}
+
+// latencyFile is a latency file written by a deliver client, named
+// <prefix>.<server>.<channel>.<client>.csv
+type latencyFile struct {
+	Path    string
+	Prefix  string
+	Server  int
+	Channel int
+	Client  int
+}
+
+// byLatencyFile sorts latency files by prefix, server, channel and client.
+type byLatencyFile []*latencyFile
+
+func (b byLatencyFile) Len() int      { return len(b) }
+func (b byLatencyFile) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byLatencyFile) Less(i, j int) bool {
+	switch {
+	case b[i].Prefix != b[j].Prefix:
+		return b[i].Prefix < b[j].Prefix
+	case b[i].Server != b[j].Server:
+		return b[i].Server < b[j].Server
+	case b[i].Channel != b[j].Channel:
+		return b[i].Channel < b[j].Channel
+	}
+	return b[i].Client < b[j].Client
+}
+
+// findLatencyFiles returns the latency files in a directory, sorted. If the
+// prefix is not empty, only files with that prefix are returned.
+func findLatencyFiles(dir, prefix string) ([]*latencyFile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	var files []*latencyFile
+	for _, path := range paths {
+		parts := strings.Split(strings.TrimSuffix(filepath.Base(path), ".csv"), ".")
+		if len(parts) < 4 {
+			continue
+		}
+		n := len(parts)
+		f := &latencyFile{
+			Path:   path,
+			Prefix: strings.Join(parts[:n-3], "."),
+		}
+		var err1, err2, err3 error
+		f.Server, err1 = strconv.Atoi(parts[n-3])
+		f.Channel, err2 = strconv.Atoi(parts[n-2])
+		f.Client, err3 = strconv.Atoi(parts[n-1])
+		if (err1 != nil) || (err2 != nil) || (err3 != nil) {
+			continue
+		}
+		if (prefix != "") && (f.Prefix != prefix) {
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Sort(byLatencyFile(files))
+	return files, nil
+}
+
+// scan reads a latency file, calling txFn for each row of a file of all TX
+// latencies, or blockFn for each row of a block latency file. The format is
+// recognized from the header line, and the columns are found by name, so the
+// files written by older versions of obx are also accepted.
+func (f *latencyFile) scan(txFn func(*latencyTx), blockFn func(*latencyBlock)) error {
+
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+
+	if !scanner.Scan() {
+		return fmt.Errorf("%s: Missing header line", f.Path)
+	}
+	columns := make(map[string]int)
+	for i, name := range strings.Split(scanner.Text(), ",") {
+		columns[strings.TrimSpace(name)] = i
+	}
+	_, isTx := columns["Sequence"]
+	_, isBlock := columns["NumTX"]
+	if !isTx && !isBlock {
+		return fmt.Errorf("%s: Not a latency file", f.Path)
+	}
+
+	var fields []string
+	var line int
+	var bad error
+	column := func(name string) string {
+		if i, ok := columns[name]; ok && (i < len(fields)) {
+			return fields[i]
+		}
+		return ""
+	}
+	number := func(name string) float64 {
+		s := strings.TrimSuffix(column(name), "d") // Old block files
+		if s == "" {
+			return 0
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if (err != nil) && (bad == nil) {
+			bad = fmt.Errorf("%s:%d: Invalid %s", f.Path, line, name)
+		}
+		return v
+	}
+	optional := func(name string) int64 {
+		if _, ok := columns[name]; !ok {
+			return -1
+		}
+		return int64(number(name))
+	}
+
+	for line = 2; scanner.Scan(); line++ {
+		if scanner.Text() == "" {
+			continue
+		}
+		fields = strings.Split(scanner.Text(), ",")
+		if isTx {
+			txFn(&latencyTx{
+				Server:     int(number("Server")),
+				Channel:    int(number("Channel")),
+				Client:     int(number("Client")),
+				Sequence:   uint64(number("Sequence")),
+				Tbroadcast: number("Tbroadcast"),
+				Tdelivered: number("Tdelivered"),
+				Latency:    number("Latency"),
+				Size:       uint64(number("Size")),
+				Block:      optional("Block"),
+				Index:      optional("Index"),
+			})
+		} else {
+			blockFn(&latencyBlock{
+				Block:      int64(number("Block")),
+				NumTX:      uint64(number("NumTX")),
+				Bytes:      uint64(number("Bytes")),
+				Tfirst:     number("Tfirst"),
+				Tdelivered: number("Tdelivered"),
+				Formation:  number("Formation"),
+				Gap:        number("Gap"),
+				MinLatency: number("MinLatency"),
+				MaxLatency: number("MaxLatency"),
+			})
+		}
+		if bad != nil {
+			return bad
+		}
+	}
+	return scanner.Err()
+}
+
+// configFile returns the name of the file where the control process saves
+// the configuration of a run in the latency directory.
+func configFile(cfg *Config) string {
+	return filepath.Join(cfg.LatencyDir, cfg.LatencyPrefix+".config.json")
+}
+
+// saveConfig saves the configuration of a run as JSON in the latency
+// directory, so that tools that read the latency files can show it.
+func saveConfig(cfg *Config) error {
+	out, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(configFile(cfg))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s\n", out)
+	return err
+}
@@ -0,0 +1,153 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLatencyFileScan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name   string
+		text   string
+		tx     []latencyTx
+		blocks []latencyBlock
+	}{
+		{
+			name: "all",
+			text: "Server,Channel,Client,Sequence,Tbroadcast,Tdelivered,Latency,Size,Block,Index\n" +
+				"1,2,3,4,1.000000000,1.500000000,0.500000000,100,7,8\n",
+			tx: []latencyTx{{1, 2, 3, 4, 1, 1.5, 0.5, 100, 7, 8}},
+		},
+		{
+			name: "old all",
+			text: "Server,Channel,Client,Sequence,Tbroadcast,Tdelivered,Latency\n" +
+				"1,2,3,4,1.000000000,1.500000000,0.500000000\n" +
+				"\n" +
+				"1,2,3,5,2.000000000,2.250000000,0.250000000\n",
+			tx: []latencyTx{
+				{1, 2, 3, 4, 1, 1.5, 0.5, 0, -1, -1},
+				{1, 2, 3, 5, 2, 2.25, 0.25, 0, -1, -1},
+			},
+		},
+		{
+			name: "blocks",
+			text: "Block,NumTX,Bytes,Tfirst,Tdelivered,Formation,Gap,MinLatency,MaxLatency\n" +
+				"5,10,1000,1.000000000,2.000000000,1.000000000,0.000000000,0.500000000,1.000000000\n",
+			blocks: []latencyBlock{{5, 10, 1000, 1, 2, 1, 0, 0.5, 1}},
+		},
+		{
+			name: "old blocks",
+			text: "Block,NumTX,Tdelivered,MinLatency,MaxLatency\n" +
+				"0,10,2.000000000d,0.500000000,1.000000000\n",
+			blocks: []latencyBlock{{Block: 0, NumTX: 10, Tdelivered: 2, MinLatency: 0.5, MaxLatency: 1}},
+		},
+	}
+	for _, tt := range tests {
+		f := &latencyFile{Path: filepath.Join(dir, "test.0.0.0.csv")}
+		if err := ioutil.WriteFile(f.Path, []byte(tt.text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var tx []latencyTx
+		var blocks []latencyBlock
+		err := f.scan(
+			func(r *latencyTx) { tx = append(tx, *r) },
+			func(r *latencyBlock) { blocks = append(blocks, *r) })
+		if err != nil {
+			t.Errorf("%s: scan() failed: %s", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(tx, tt.tx) || !reflect.DeepEqual(blocks, tt.blocks) {
+			t.Errorf("%s: scan() = %+v, %+v; want %+v, %+v",
+				tt.name, tx, blocks, tt.tx, tt.blocks)
+		}
+	}
+}
+
+func TestLatencyFileScanErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"empty", ""},
+		{"not a latency file", "a,b,c\n1,2,3\n"},
+		{"invalid number", "Block,NumTX\n1,x\n"},
+	}
+	for _, tt := range tests {
+		f := &latencyFile{Path: filepath.Join(dir, "test.0.0.0.csv")}
+		if err := ioutil.WriteFile(f.Path, []byte(tt.text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.scan(func(*latencyTx) {}, func(*latencyBlock) {}); err == nil {
+			t.Errorf("%s: scan() succeeded", tt.name)
+		}
+	}
+}
+
+func TestFindLatencyFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{
+		"b.0.0.0.csv", "a.1.0.0.csv", "a.0.10.0.csv", "a.0.2.0.csv",
+		"a.config.json", "x.csv", "a.0.0.x.csv",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"a.0.2.0.csv", "a.0.10.0.csv", "a.1.0.0.csv", "b.0.0.0.csv"}},
+		{"b", []string{"b.0.0.0.csv"}},
+		{"c", nil},
+	}
+	for _, tt := range tests {
+		files, err := findLatencyFiles(dir, tt.prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, f := range files {
+			got = append(got, filepath.Base(f.Path))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findLatencyFiles(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/orderer"
+	"github.com/hyperledger/fabric/protos/utils"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/grpc"
+)
+
+// seekOldest returns the position of the oldest block of the ledger.
+func seekOldest() *orderer.SeekPosition {
+	return &orderer.SeekPosition{
+		Type: &orderer.SeekPosition_Oldest{
+			Oldest: &orderer.SeekOldest{},
+		},
+	}
+}
+
+// seekNewest returns the position of the newest block of the ledger at the
+// time the seek request is made.
+func seekNewest() *orderer.SeekPosition {
+	return &orderer.SeekPosition{
+		Type: &orderer.SeekPosition_Newest{
+			Newest: &orderer.SeekNewest{},
+		},
+	}
+}
+
+// seekSpecified returns the position of a numbered block.
+func seekSpecified(number uint64) *orderer.SeekPosition {
+	return &orderer.SeekPosition{
+		Type: &orderer.SeekPosition_Specified{
+			Specified: &orderer.SeekSpecified{
+				Number: number},
+		},
+	}
+}
+
+// seekStart returns the position where delivery starts, which is the oldest
+// block unless a start block is configured.
+func seekStart(cfg *Config) *orderer.SeekPosition {
+	if cfg.StartBlock != 0 {
+		return seekSpecified(cfg.StartBlock)
+	}
+	return seekOldest()
+}
+
+// seekStop returns the position where delivery stops. Discovered runs stop at
+// the last block discovered, and report any TX not found as missing; Other
+// runs deliver until they have received every TX expected.
+func seekStop(cfg *Config) *orderer.SeekPosition {
+	if cfg.Discover {
+		return seekSpecified(cfg.StopBlock)
+	}
+	return seekSpecified(math.MaxUint64)
+}
+
+// seekEnvelope creates the envelope of a request to deliver the blocks of a
+// chain from start through stop, waiting for blocks that have not been
+// created yet.
+func seekEnvelope(chainID string, start, stop *orderer.SeekPosition) *common.Envelope {
+	return &common.Envelope{
+		Payload: utils.MarshalOrPanic(&common.Payload{
+			Header: &common.Header{
+				ChainHeader: &common.ChainHeader{
+					ChainID: chainID,
+				},
+				SignatureHeader: &common.SignatureHeader{},
+			},
+			Data: utils.MarshalOrPanic(&orderer.SeekInfo{
+				Start:    start,
+				Stop:     stop,
+				Behavior: orderer.SeekInfo_BLOCK_UNTIL_READY,
+			}),
+		}),
+	}
+}
+
+// blockMessages returns the messages (payload data) of the transactions of a
+// block.
+func blockMessages(block *common.Block) ([][]byte, error) {
+	envelope := new(common.Envelope)
+	payload := new(common.Payload)
+	messages := make([][]byte, len(block.Data.Data))
+	for i, transaction := range block.Data.Data {
+		if err := proto.Unmarshal(transaction, envelope); err != nil {
+			return nil, fmt.Errorf("Unmarshal to Envelope failed: %s", err)
+		}
+		if err := proto.Unmarshal(envelope.Payload, payload); err != nil {
+			return nil, fmt.Errorf("Unmarshal to Payload failed: %s", err)
+		}
+		messages[i] = payload.Data
+	}
+	return messages, nil
+}
+
+// scanLedger delivers the blocks of a chain from start through stop from a
+// deliver server, calling fn for each block. Unlike the deliver clients,
+// which run until they have received the transactions they expect, a scan
+// ends when the server reports that the stop block has been delivered.
+func scanLedger(cfg *Config, server, chainID string,
+	start, stop *orderer.SeekPosition, fn func(*common.Block) error) error {
+
+	connection, err := grpc.Dial(server, dialOptions(cfg)...)
+	if err != nil {
+		return fmt.Errorf("Could not connect to %s: %s", server, err)
+	}
+	defer connection.Close()
+	stream, err :=
+		orderer.NewAtomicBroadcastClient(connection).Deliver(context.Background())
+	if err != nil {
+		return fmt.Errorf("Failed to invoke deliver RPC on %s: %s", server, err)
+	}
+	return seekBlocks(stream, server, chainID, start, stop, fn)
+}
+
+// seekBlocks requests the blocks of a chain from start through stop on an
+// open deliver stream, calling fn for each block, and returns once the server
+// reports that the stop block has been delivered. The stream can then be
+// used for another request.
+func seekBlocks(stream orderer.AtomicBroadcast_DeliverClient, server, chainID string,
+	start, stop *orderer.SeekPosition, fn func(*common.Block) error) error {
+
+	if err := stream.Send(seekEnvelope(chainID, start, stop)); err != nil {
+		return fmt.Errorf("Failed to send seek request to %s: %s", server, err)
+	}
+
+	for {
+		reply, err := stream.Recv()
+		if err != nil {
+			return fmt.Errorf("Reply error from %s: %s", server, err)
+		}
+		switch t := reply.Type.(type) {
+		case *orderer.DeliverResponse_Block:
+			if err = fn(t.Block); err != nil {
+				return err
+			}
+		case *orderer.DeliverResponse_Status:
+			if t.Status == common.Status_SUCCESS {
+				return nil
+			}
+			return fmt.Errorf("Server %s delivered status response: %s",
+				server, t.Status.String())
+		}
+	}
+}
@@ -35,7 +35,15 @@ var logger *logging.Logger
 //     obx deliver ... args ...
 //
 // taking advantage of the fact that "broadcast" and "deliver" are not valid
-// flags.
+// flags. The ledger inspector, the HTML report generator, the latency analyzer,
+// the results comparator and the client self-test are invoked from the
+// command line as
+//
+//     obx inspect ... flags ...
+//     obx report ... flags ... <latency directory>
+//     obx analyze ... flags ... <latency directory>
+//     obx compare ... flags ... <baseline> <current>
+//     obx selftest ... flags ...
 func main() {
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
@@ -43,6 +51,16 @@ func main() {
 			broadcast()
 		case "deliver":
 			deliver()
+		case "inspect":
+			inspect()
+		case "report":
+			htmlReport()
+		case "analyze":
+			analyze()
+		case "compare":
+			compare()
+		case "selftest":
+			selftest()
 		default:
 			control()
 		}
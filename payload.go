@@ -0,0 +1,253 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Payload size distribution kinds
+const (
+	FixedPayload     = "fixed"
+	UniformPayload   = "uniform"
+	NormalPayload    = "normal"
+	BimodalPayload   = "bimodal"
+	EmpiricalPayload = "empirical"
+)
+
+// PayloadDist describes the distribution of transaction payload sizes. The
+// sizes of bimodal and empirical distributions are stored with their
+// cumulative probabilities. Every size is at least TxHeaderSize.
+type PayloadDist struct {
+	Kind       string    // One of the payload distribution kinds
+	Min        int       // Fixed size, or the minimum uniform size
+	Max        int       // Maximum uniform size
+	Mean       float64   // Mean normal size
+	Stddev     float64   // Standard deviation of normal sizes
+	Sizes      []int     // Bimodal and empirical sizes
+	Cumulative []float64 // Cumulative probability of each size
+}
+
+// parsePayloadDist parses the -payloadDist flag. The fixed distribution uses
+// the -payload size; The others are given as
+//
+//	uniform:MIN,MAX
+//	normal:MEAN,STDDEV
+//	bimodal:SIZE1,SIZE2,PROBABILITY1
+//	empirical:FILE
+//
+// where FILE contains lines of "SIZE WEIGHT" (or "SIZE,WEIGHT"), with
+// comments introduced by '#'.
+func parsePayloadDist(val string, payload int) (d PayloadDist) {
+
+	why := "fixed, uniform:MIN,MAX, normal:MEAN,STDDEV, " +
+		"bimodal:SIZE1,SIZE2,PROBABILITY1 or empirical:FILE"
+
+	kv := strings.SplitN(val, ":", 2)
+	d.Kind = kv[0]
+	var args []float64
+	if (len(kv) == 2) && (d.Kind != EmpiricalPayload) {
+		for _, s := range strings.Split(kv[1], ",") {
+			f, err := strconv.ParseFloat(s, 64)
+			if (err != nil) || (f < 0) {
+				bogus("payloadDist", why)
+			}
+			args = append(args, f)
+		}
+	}
+
+	switch {
+	case (d.Kind == FixedPayload) && (len(kv) == 1):
+		d.Min = payload
+		d.Max = payload
+	case (d.Kind == UniformPayload) && (len(args) == 2):
+		d.Min = atLeastHeader(args[0])
+		d.Max = atLeastHeader(args[1])
+		if d.Min > d.Max {
+			bogus("payloadDist", "a uniform distribution with MIN <= MAX")
+		}
+	case (d.Kind == NormalPayload) && (len(args) == 2):
+		d.Mean = args[0]
+		d.Stddev = args[1]
+		d.Min = TxHeaderSize
+		d.Max = atLeastHeader(d.Mean + 6*d.Stddev)
+	case (d.Kind == BimodalPayload) && (len(args) == 3):
+		if args[2] > 1 {
+			bogus("payloadDist", "a bimodal distribution with PROBABILITY1 <= 1")
+		}
+		d.setSizes(
+			[]int{atLeastHeader(args[0]), atLeastHeader(args[1])},
+			[]float64{args[2], 1 - args[2]})
+	case (d.Kind == EmpiricalPayload) && (len(kv) == 2):
+		sizes, weights := readPayloadHistogram(kv[1])
+		d.setSizes(sizes, weights)
+	default:
+		bogus("payloadDist", why)
+	}
+	return
+}
+
+// atLeastHeader rounds a size, and raises it to TxHeaderSize if necessary.
+func atLeastHeader(size float64) int {
+	if size < TxHeaderSize {
+		return TxHeaderSize
+	}
+	return int(size + 0.5)
+}
+
+// setSizes sets up a discrete distribution from sizes and their (not
+// necessarily normalized) weights.
+func (d *PayloadDist) setSizes(sizes []int, weights []float64) {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		bogus("payloadDist", "a distribution with a positive total weight")
+	}
+	d.Sizes = sizes
+	d.Cumulative = make([]float64, len(weights))
+	var sum float64
+	d.Min = math.MaxInt32
+	for i, w := range weights {
+		sum += w
+		d.Cumulative[i] = sum / total
+		if sizes[i] < d.Min {
+			d.Min = sizes[i]
+		}
+		if sizes[i] > d.Max {
+			d.Max = sizes[i]
+		}
+	}
+	d.Cumulative[len(weights)-1] = 1 // Guard against rounding
+}
+
+// readPayloadHistogram reads the sizes and weights of an empirical
+// distribution from a file.
+func readPayloadHistogram(path string) (sizes []int, weights []float64) {
+	f, err := os.Open(path)
+	if err != nil {
+		logger.Fatalf("Error opening payload histogram file: %s", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(strings.Replace(text, ",", " ", -1))
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			logger.Fatalf("%s:%d: Expected SIZE WEIGHT", path, line)
+		}
+		size, err1 := strconv.ParseFloat(fields[0], 64)
+		weight, err2 := strconv.ParseFloat(fields[1], 64)
+		if (err1 != nil) || (err2 != nil) || (size < 0) || (weight < 0) {
+			logger.Fatalf("%s:%d: Invalid SIZE or WEIGHT", path, line)
+		}
+		sizes = append(sizes, atLeastHeader(size))
+		weights = append(weights, weight)
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Fatalf("Error reading payload histogram file: %s", err)
+	}
+	if len(sizes) == 0 {
+		logger.Fatalf("Payload histogram file %s is empty", path)
+	}
+	return
+}
+
+// mean returns the mean payload size, used to estimate byte counts before a
+// run. The normal distribution is clipped, so this is only an estimate.
+func (d *PayloadDist) mean() float64 {
+	switch d.Kind {
+	case UniformPayload:
+		return float64(d.Min+d.Max) / 2
+	case NormalPayload:
+		return math.Max(d.Mean, TxHeaderSize)
+	case BimodalPayload, EmpiricalPayload:
+		var mean, last float64
+		for i, c := range d.Cumulative {
+			mean += (c - last) * float64(d.Sizes[i])
+			last = c
+		}
+		return mean
+	}
+	return float64(d.Min)
+}
+
+func (d PayloadDist) String() string {
+	switch d.Kind {
+	case UniformPayload:
+		return "uniform " + strconv.Itoa(d.Min) + " - " + strconv.Itoa(d.Max)
+	case NormalPayload:
+		return "normal mean " + strconv.FormatFloat(d.Mean, 'f', -1, 64) +
+			", stddev " + strconv.FormatFloat(d.Stddev, 'f', -1, 64)
+	case BimodalPayload, EmpiricalPayload:
+		return d.Kind + " with " + strconv.Itoa(len(d.Sizes)) +
+			" sizes, mean " + strconv.FormatFloat(d.mean(), 'f', 1, 64)
+	}
+	return "fixed " + strconv.Itoa(d.Min)
+}
+
+// payloadSizer generates a stream of payload sizes from a distribution.
+// Sizers are seeded from the broadcast client identity, so every run with the
+// same configuration generates the same sizes.
+type payloadSizer struct {
+	dist *PayloadDist
+	rng  *rand.Rand
+}
+
+// newPayloadSizer creates a payloadSizer for a broadcast client.
+func newPayloadSizer(dist *PayloadDist, client *Client) *payloadSizer {
+	return &payloadSizer{
+		dist: dist,
+		rng:  rand.New(rand.NewSource(client.seed())),
+	}
+}
+
+// next returns the next payload size.
+func (s *payloadSizer) next() int {
+	d := s.dist
+	var size int
+	switch d.Kind {
+	case UniformPayload:
+		size = d.Min + s.rng.Intn(d.Max-d.Min+1)
+	case NormalPayload:
+		size = int(s.rng.NormFloat64()*d.Stddev + d.Mean + 0.5)
+	case BimodalPayload, EmpiricalPayload:
+		size = d.Sizes[sort.SearchFloat64s(d.Cumulative, s.rng.Float64())]
+	default:
+		return d.Min
+	}
+	if size < d.Min {
+		return d.Min
+	}
+	if size > d.Max {
+		return d.Max
+	}
+	return size
+}
@@ -0,0 +1,103 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParsePayloadDist(t *testing.T) {
+	tests := []struct {
+		val  string
+		want PayloadDist
+	}{
+		{"fixed", PayloadDist{Kind: FixedPayload, Min: 100, Max: 100}},
+		{"uniform:100,200", PayloadDist{Kind: UniformPayload, Min: 100, Max: 200}},
+		{"uniform:0,200", PayloadDist{Kind: UniformPayload, Min: TxHeaderSize, Max: 200}},
+		{"normal:1000,100", PayloadDist{
+			Kind: NormalPayload, Min: TxHeaderSize, Max: 1600, Mean: 1000, Stddev: 100}},
+		{"bimodal:100,1000,0.25", PayloadDist{
+			Kind: BimodalPayload, Min: 100, Max: 1000,
+			Sizes: []int{100, 1000}, Cumulative: []float64{0.25, 1}}},
+	}
+	for _, tt := range tests {
+		if got := parsePayloadDist(tt.val, 100); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePayloadDist(%q) = %+v, want %+v", tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestParsePayloadDistEmpirical(t *testing.T) {
+	f, err := ioutil.TempFile("", "obx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("# Size Weight\n100 1\n\n200,2 # Comment\n10 1\n")
+	f.Close()
+
+	want := PayloadDist{
+		Kind: EmpiricalPayload, Min: TxHeaderSize, Max: 200,
+		Sizes: []int{100, 200, TxHeaderSize}, Cumulative: []float64{0.25, 0.75, 1},
+	}
+	got := parsePayloadDist("empirical:"+f.Name(), 100)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePayloadDist(empirical) = %+v, want %+v", got, want)
+	}
+	if m := got.mean(); m != (100+400+TxHeaderSize)/4.0 {
+		t.Errorf("mean() = %v", m)
+	}
+}
+
+func TestPayloadSizer(t *testing.T) {
+	tests := []string{
+		"fixed",
+		"uniform:100,200",
+		"normal:1000,300",
+		"bimodal:100,1000,0.25",
+	}
+	const n = 100000
+	for _, val := range tests {
+		d := parsePayloadDist(val, 500)
+		s := newPayloadSizer(&d, &Client{Server: 1, Channel: 2, Client: 3})
+		var sum float64
+		for i := 0; i < n; i++ {
+			size := s.next()
+			if (size < d.Min) || (size > d.Max) {
+				t.Fatalf("%s: size %d is outside [%d, %d]", val, size, d.Min, d.Max)
+			}
+			sum += float64(size)
+		}
+		if mean := sum / n; math.Abs(mean-d.mean()) > 0.01*d.mean() {
+			t.Errorf("%s: mean size %v, want %v", val, mean, d.mean())
+		}
+
+		// The sizes are repeatable for a client.
+
+		s1 := newPayloadSizer(&d, &Client{Server: 1, Channel: 2, Client: 3})
+		s2 := newPayloadSizer(&d, &Client{Server: 1, Channel: 2, Client: 3})
+		for i := 0; i < 100; i++ {
+			if a, b := s1.next(), s2.next(); a != b {
+				t.Fatalf("%s: size %d differs: %d and %d", val, i, a, b)
+			}
+		}
+	}
+}
@@ -0,0 +1,294 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+// Phase identifies one of the phases of a run. The transactions of each
+// broadcast client are divided into an initial warm-up phase, a ramp phase
+// during which the broadcast rate increases to the configured rate, a steady
+// phase, and a final cool-down phase. Warm-up and cool-down transactions are
+// excluded from the measured throughput and latency statistics.
+type Phase int
+
+const (
+	Warmup Phase = iota
+	Ramp
+	Steady
+	Cooldown
+	NumPhases
+)
+
+var phaseNames = [NumPhases]string{"Warm-up", "Ramp", "Steady", "Cool-down"}
+
+func (p Phase) String() string {
+	return phaseNames[p]
+}
+
+// measured returns true for the phases included in the measured statistics.
+func (p Phase) measured() bool {
+	return (p == Ramp) || (p == Steady)
+}
+
+// PhaseLength is the length of a phase, given either as a number of
+// transactions per broadcast client or as a duration. At most one of the
+// fields is non-zero.
+type PhaseLength struct {
+	Tx       uint64
+	Duration time.Duration
+}
+
+// PhaseLengths holds the lengths of the phases, indexed by Phase.
+type PhaseLengths [NumPhases]PhaseLength
+
+// parsePhaseLength parses a phase length flag, which is either an integer
+// transaction count or a duration in the form required by
+// time.ParseDuration().
+func parsePhaseLength(flag, val string) (l PhaseLength) {
+	if val == "" {
+		return
+	}
+	if n, err := strconv.ParseUint(val, 10, 32); err == nil {
+		l.Tx = n
+		return
+	}
+	d, err := time.ParseDuration(val)
+	if (err != nil) || (d < 0) {
+		bogus(flag, "a transaction count or a positive duration")
+	}
+	l.Duration = d
+	return
+}
+
+func (l PhaseLength) String() string {
+	if l.Duration != 0 {
+		return l.Duration.String()
+	}
+	return strconv.FormatUint(l.Tx, 10) + " TX"
+}
+
+// PhaseStats accumulates the transactions observed by a client during a
+// phase. Times are ns since the common start time. For broadcast clients the
+// times are broadcast times; For deliver clients they are delivery times, and
+// the latency histogram records broadcast-to-delivery latencies.
+type PhaseStats struct {
+	Tx      uint64    // # of TX
+	Bytes   uint64    // Payload bytes
+	Tfirst  uint64    // Time of the first TX
+	Tlast   uint64    // Time of the last TX
+	Latency Histogram // Latencies (ns)
+}
+
+// add accounts for a transaction observed at time t.
+func (p *PhaseStats) add(t, bytes uint64) {
+	if (p.Tx == 0) || (t < p.Tfirst) {
+		p.Tfirst = t
+	}
+	if t > p.Tlast {
+		p.Tlast = t
+	}
+	p.Tx++
+	p.Bytes += bytes
+}
+
+// merge adds the contents of another PhaseStats to this one.
+func (p *PhaseStats) merge(o *PhaseStats) {
+	if o.Tx == 0 {
+		return
+	}
+	if (p.Tx == 0) || (o.Tfirst < p.Tfirst) {
+		p.Tfirst = o.Tfirst
+	}
+	if o.Tlast > p.Tlast {
+		p.Tlast = o.Tlast
+	}
+	p.Tx += o.Tx
+	p.Bytes += o.Bytes
+	p.Latency.Merge(&o.Latency)
+}
+
+// measuredStats returns the statistics of the measured phases combined.
+func measuredStats(phases *[NumPhases]PhaseStats) (m PhaseStats) {
+	for p := range phases {
+		if Phase(p).measured() {
+			m.merge(&phases[p])
+		}
+	}
+	return
+}
+
+// duration returns the time spanned by the phase in float64-seconds.
+func (p *PhaseStats) duration() float64 {
+	return float64(p.Tlast-p.Tfirst) / 1e9
+}
+
+// rate returns n per second over the time spanned by the phase, or 0 if the
+// phase does not span any time.
+func (p *PhaseStats) rate(n uint64) float64 {
+	if p.Tlast == p.Tfirst {
+		return 0
+	}
+	return float64(n) / p.duration()
+}
+
+// phaseEvent is a transaction whose phase has not yet been decided.
+type phaseEvent struct {
+	tBroadcast uint64
+	t          uint64
+	bytes      uint64
+	latency    uint64
+}
+
+// phaseTracker classifies the transactions of a single broadcast client into
+// phases, and accumulates them into PhaseStats. Broadcast clients track their
+// own transactions, and deliver clients track each broadcast client they
+// receive transactions from. Transactions must be added in broadcast order.
+//
+// Cool-down is the tail of the broadcast client's transactions. When it is
+// given as a duration its extent is only known once the last transaction has
+// been seen, so candidate transactions are held until they are definitely
+// older than the cool-down window, or until finish() is called.
+type phaseTracker struct {
+	cfg     *Config
+	total   uint64                 // # of TX from this broadcast client
+	latency bool                   // Record latencies?
+	stats   *[NumPhases]PhaseStats // Where the results go
+	pending []phaseEvent           // Cool-down candidates
+}
+
+// newPhaseTracker creates a phaseTracker for a broadcast client that
+// broadcasts total transactions.
+func newPhaseTracker(
+	cfg *Config, total uint64, latency bool,
+	stats *[NumPhases]PhaseStats) *phaseTracker {
+
+	return &phaseTracker{
+		cfg:     cfg,
+		total:   total,
+		latency: latency,
+		stats:   stats,
+	}
+}
+
+// add classifies and accounts for a transaction. The sequence number and
+// broadcast time (ns since the start) determine the phase, t is the time the
+// transaction was observed, and latency is only recorded if the tracker
+// tracks latencies.
+func (pt *phaseTracker) add(
+	sequence uint32, tBroadcast, t, bytes, latency uint64) {
+
+	e := phaseEvent{tBroadcast: tBroadcast, t: t, bytes: bytes, latency: latency}
+	p := pt.cfg.phase(sequence, tBroadcast, pt.total)
+	if (p != Steady) || (pt.cfg.Phases[Cooldown].Duration == 0) {
+		pt.account(p, &e)
+		return
+	}
+
+	// This TX is a cool-down candidate. Anything held that is older than the
+	// cool-down window is now known to be steady.
+
+	window := uint64(pt.cfg.Phases[Cooldown].Duration)
+	for len(pt.pending) != 0 {
+		if pt.pending[0].tBroadcast+window > tBroadcast {
+			break
+		}
+		pt.account(Steady, &pt.pending[0])
+		pt.pending = pt.pending[1:]
+	}
+	pt.pending = append(pt.pending, e)
+}
+
+// finish is called once all transactions have been added; Any transactions
+// still held are in the cool-down window.
+func (pt *phaseTracker) finish() {
+	for i := range pt.pending {
+		pt.account(Cooldown, &pt.pending[i])
+	}
+	pt.pending = nil
+}
+
+func (pt *phaseTracker) account(p Phase, e *phaseEvent) {
+	s := &pt.stats[p]
+	s.add(e.t, e.bytes)
+	if pt.latency {
+		s.Latency.Record(e.latency)
+	}
+}
+
+// phased returns true if any phase other than the steady phase is configured.
+func (c *Config) phased() bool {
+	for p, l := range c.Phases {
+		if (Phase(p) != Steady) && ((l.Tx != 0) || (l.Duration != 0)) {
+			return true
+		}
+	}
+	return false
+}
+
+// phase returns the phase of a transaction from a broadcast client that
+// broadcasts total transactions, based on its sequence number or, for timed
+// phases, its broadcast time (ns since the start). A transaction that may be
+// in a timed cool-down window is reported as Steady; See phaseTracker.
+func (c *Config) phase(sequence uint32, tBroadcast, total uint64) Phase {
+	if c.TimedPhases {
+		t := time.Duration(tBroadcast)
+		warmup := c.Phases[Warmup].Duration
+		switch {
+		case t < warmup:
+			return Warmup
+		case t < warmup+c.Phases[Ramp].Duration:
+			return Ramp
+		}
+		return Steady
+	}
+	seq := uint64(sequence)
+	warmup := c.Phases[Warmup].Tx
+	switch {
+	case seq < warmup:
+		return Warmup
+	case seq < warmup+c.Phases[Ramp].Tx:
+		return Ramp
+	case seq+c.Phases[Cooldown].Tx >= total:
+		return Cooldown
+	}
+	return Steady
+}
+
+// burstDelay returns the delay a broadcast client with the given workload
+// waits after a burst ending with the given transaction. During the ramp
+// phase the delay decreases linearly from -rampDelay to the workload delay.
+func (c *Config) burstDelay(w *Workload, sequence uint32, tBroadcast uint64) time.Duration {
+	if (c.RampDelay <= w.Delay) ||
+		(c.phase(sequence, tBroadcast, uint64(w.Transactions)) != Ramp) {
+		return w.Delay
+	}
+	var progress float64
+	if c.TimedPhases {
+		progress =
+			float64(time.Duration(tBroadcast)-c.Phases[Warmup].Duration) /
+				float64(c.Phases[Ramp].Duration)
+	} else {
+		progress =
+			float64(uint64(sequence)-c.Phases[Warmup].Tx) /
+				float64(c.Phases[Ramp].Tx)
+	}
+	return c.RampDelay -
+		time.Duration(progress*float64(c.RampDelay-w.Delay))
+}
@@ -0,0 +1,108 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePhaseLength(t *testing.T) {
+	tests := []struct {
+		val  string
+		want PhaseLength
+	}{
+		{"", PhaseLength{}},
+		{"0", PhaseLength{}},
+		{"100", PhaseLength{Tx: 100}},
+		{"5s", PhaseLength{Duration: 5 * time.Second}},
+		{"1m30s", PhaseLength{Duration: 90 * time.Second}},
+	}
+	for _, tt := range tests {
+		if got := parsePhaseLength("warmup", tt.val); got != tt.want {
+			t.Errorf("parsePhaseLength(%q) = %+v, want %+v", tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestPhaseByCount(t *testing.T) {
+	cfg := &Config{}
+	cfg.Phases[Warmup].Tx = 2
+	cfg.Phases[Ramp].Tx = 3
+	cfg.Phases[Cooldown].Tx = 2
+	want := []Phase{
+		Warmup, Warmup, Ramp, Ramp, Ramp, Steady, Steady, Steady, Cooldown, Cooldown,
+	}
+	for seq, p := range want {
+		if got := cfg.phase(uint32(seq), 0, uint64(len(want))); got != p {
+			t.Errorf("phase(%d) = %s, want %s", seq, got, p)
+		}
+	}
+	if !cfg.phased() {
+		t.Errorf("phased() = false, want true")
+	}
+	if (&Config{}).phased() {
+		t.Errorf("phased() of the default configuration = true, want false")
+	}
+}
+
+func TestPhaseTrackerTimed(t *testing.T) {
+	cfg := &Config{TimedPhases: true}
+	cfg.Phases[Warmup].Duration = 10
+	cfg.Phases[Ramp].Duration = 10
+	cfg.Phases[Cooldown].Duration = 15
+
+	// One TX every 5ns; The last TX is broadcast at 50, so the cool-down
+	// window holds the TX broadcast at 40, 45 and 50.
+
+	tests := []struct {
+		tBroadcast uint64
+		phase      Phase
+	}{
+		{0, Warmup}, {5, Warmup},
+		{10, Ramp}, {15, Ramp},
+		{20, Steady}, {25, Steady}, {30, Steady}, {35, Steady},
+		{40, Cooldown}, {45, Cooldown}, {50, Cooldown},
+	}
+	var stats [NumPhases]PhaseStats
+	pt := newPhaseTracker(cfg, uint64(len(tests)), true, &stats)
+	var want [NumPhases]PhaseStats
+	for i, tt := range tests {
+		pt.add(uint32(i), tt.tBroadcast, tt.tBroadcast+1, 10, tt.tBroadcast)
+		want[tt.phase].add(tt.tBroadcast+1, 10)
+		want[tt.phase].Latency.Record(tt.tBroadcast)
+	}
+	pt.finish()
+	for p := range stats {
+		got := &stats[p]
+		w := &want[p]
+		if (got.Tx != w.Tx) || (got.Bytes != w.Bytes) ||
+			(got.Tfirst != w.Tfirst) || (got.Tlast != w.Tlast) ||
+			!equalHistograms(&got.Latency, &w.Latency) {
+			t.Errorf("%s: got %+v, want %+v", Phase(p), *got, *w)
+		}
+	}
+
+	m := measuredStats(&stats)
+	if (m.Tx != 6) || (m.Bytes != 60) || (m.Tfirst != 11) || (m.Tlast != 36) ||
+		(m.Latency.N != 6) {
+		t.Errorf("measuredStats() = %+v", m)
+	}
+	if r := m.rate(m.Tx); r != 6/25e-9 {
+		t.Errorf("measured rate = %v, want %v", r, 6/25e-9)
+	}
+}
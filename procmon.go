@@ -0,0 +1,351 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The control process can sample the resource usage of local processes,
+// e.g., an orderer running on the same host, from the Linux /proc file
+// system during a run.
+
+// procClockTicks is the unit of the CPU times in /proc/<pid>/stat (USER_HZ),
+// which is 100 on all common Linux platforms.
+const procClockTicks = 100
+
+// procSample is a sample of the cumulative resource usage of a process. The
+// I/O counters are only available if /proc/<pid>/io is readable.
+type procSample struct {
+	cpu     float64 // User + system CPU time (seconds)
+	rss     uint64  // Resident set size (bytes)
+	read    uint64  // Bytes read from storage
+	written uint64  // Bytes written to storage
+	ctx     uint64  // Voluntary + involuntary context switches
+	io      bool    // Are the I/O counters valid?
+}
+
+// procInterval is the resource usage of a process in one interval of a run.
+type procInterval struct {
+	Time    float64 // End of the interval (seconds since the start)
+	CPU     float64 // CPU utilization (CPUs)
+	RSS     uint64  // Resident set size at the end of the interval (bytes)
+	Read    uint64  // Bytes read in the interval
+	Written uint64  // Bytes written in the interval
+	Ctx     uint64  // Context switches in the interval
+}
+
+// procStats is the resource usage of a monitored process during a run.
+type procStats struct {
+	pid       int
+	name      string
+	first     procSample
+	last      procSample
+	maxRSS    uint64
+	intervals []procInterval
+	exited    bool // Did the process exit during the run?
+}
+
+// procMonitor samples a set of processes at a fixed interval.
+type procMonitor struct {
+	procs    []*procStats
+	interval time.Duration
+	tStart   time.Time
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// parsePids parses a comma-separated list of process IDs.
+func parsePids(pids string) (list []int) {
+	if pids == "" {
+		return nil
+	}
+	for _, s := range strings.Split(pids, ",") {
+		pid, err := strconv.Atoi(strings.TrimSpace(s))
+		if (err != nil) || (pid <= 0) {
+			bogus("monitorPids", "a comma-separated list of process IDs")
+		}
+		list = append(list, pid)
+	}
+	return
+}
+
+// procName returns the command name of a process.
+func procName(pid int) (string, error) {
+	comm, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(comm)), nil
+}
+
+// findProcesses returns the IDs of the processes with a command name, or
+// whose command (argv[0]) has that base name. The command name is limited
+// to 15 characters by Linux.
+func findProcesses(name string) (pids []int) {
+	dirs, _ := filepath.Glob("/proc/[0-9]*")
+	self := os.Getpid()
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(filepath.Base(dir))
+		if (err != nil) || (pid == self) {
+			continue
+		}
+		if comm, err := procName(pid); (err == nil) && (comm == name) {
+			pids = append(pids, pid)
+			continue
+		}
+		cmdline, err := ioutil.ReadFile(filepath.Join(dir, "cmdline"))
+		if err != nil {
+			continue
+		}
+		argv0 := strings.SplitN(string(cmdline), "\x00", 2)[0]
+		if (argv0 != "") && (filepath.Base(argv0) == name) {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+	return
+}
+
+// sampleProc samples the resource usage of a process.
+func sampleProc(pid int) (s procSample, err error) {
+
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+
+	// The command name in stat is in parentheses and may contain spaces,
+	// so the fields are counted from the closing parenthesis. utime and
+	// stime are fields 14 and 15.
+
+	stat, err := ioutil.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return
+	}
+	paren := strings.LastIndex(string(stat), ")")
+	fields := strings.Fields(string(stat[paren+1:]))
+	if (paren < 0) || (len(fields) < 13) {
+		err = fmt.Errorf("%s/stat: Unexpected format", dir)
+		return
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	s.cpu = float64(utime+stime) / procClockTicks
+
+	values := func(file string) (map[string]uint64, error) {
+		f, err := os.Open(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		m := make(map[string]uint64)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			kv := strings.SplitN(scanner.Text(), ":", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			v := strings.Fields(kv[1])
+			if len(v) == 0 {
+				continue
+			}
+			if n, err := strconv.ParseUint(v[0], 10, 64); err == nil {
+				m[kv[0]] = n
+			}
+		}
+		return m, scanner.Err()
+	}
+
+	status, err := values("status")
+	if err != nil {
+		return
+	}
+	s.rss = status["VmRSS"] * 1024
+	s.ctx = status["voluntary_ctxt_switches"] + status["nonvoluntary_ctxt_switches"]
+
+	if io, ioErr := values("io"); ioErr == nil {
+		s.read = io["read_bytes"]
+		s.written = io["write_bytes"]
+		s.io = true
+	}
+	return
+}
+
+// startProcMonitor starts sampling the processes configured for monitoring,
+// returning nil if there are none.
+func startProcMonitor(cfg *Config, tStart time.Time) *procMonitor {
+
+	pids := append([]int{}, cfg.MonitorPids...)
+	if cfg.MonitorProcess != "" {
+		found := findProcesses(cfg.MonitorProcess)
+		if len(found) == 0 {
+			logger.Warningf("No process named %s found to monitor", cfg.MonitorProcess)
+		}
+		pids = append(pids, found...)
+	}
+	if len(pids) == 0 {
+		return nil
+	}
+
+	m := &procMonitor{
+		interval: cfg.MonitorInterval,
+		tStart:   tStart,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, pid := range pids {
+		s, err := sampleProc(pid)
+		if err != nil {
+			logger.Errorf("Can not monitor process %d: %s", pid, err)
+			continue
+		}
+		name, _ := procName(pid)
+		if !s.io {
+			logger.Warningf("The I/O of process %d (%s) is not readable, "+
+				"and will not be reported", pid, name)
+		}
+		m.procs = append(m.procs, &procStats{
+			pid: pid, name: name, first: s, last: s, maxRSS: s.rss,
+		})
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.sample()
+			case <-m.stop:
+				m.sample()
+				close(m.done)
+				return
+			}
+		}
+	}()
+	return m
+}
+
+// sample samples each process that is still running, closing an interval.
+func (m *procMonitor) sample() {
+	now := time.Since(m.tStart).Seconds()
+	for _, p := range m.procs {
+		if p.exited {
+			continue
+		}
+		s, err := sampleProc(p.pid)
+		if err != nil {
+			logger.Warningf("Process %d (%s) is no longer readable; Monitoring stops: %s",
+				p.pid, p.name, err)
+			p.exited = true
+			continue
+		}
+		start := float64(0)
+		if n := len(p.intervals); n != 0 {
+			start = p.intervals[n-1].Time
+		}
+		iv := procInterval{
+			Time:    now,
+			RSS:     s.rss,
+			Read:    s.read - p.last.read,
+			Written: s.written - p.last.written,
+			Ctx:     s.ctx - p.last.ctx,
+		}
+		if now > start {
+			iv.CPU = (s.cpu - p.last.cpu) / (now - start)
+		}
+		p.intervals = append(p.intervals, iv)
+		p.last = s
+		if s.rss > p.maxRSS {
+			p.maxRSS = s.rss
+		}
+	}
+}
+
+// finish stops the monitor after a final sample, and returns the statistics
+// of the processes.
+func (m *procMonitor) finish() []*procStats {
+	if m == nil {
+		return nil
+	}
+	close(m.stop)
+	<-m.done
+	return m.procs
+}
+
+// reportProcs prints the resource usage of the monitored processes, per
+// interval and in total, with the CPU time and bytes written per TX. The TX
+// are those broadcast, or those delivered in deliver-only runs.
+func reportProcs(cfg *Config, procs []*procStats) {
+
+	tx := cfg.TotalTxBroadcast
+	what := "TX broadcast"
+	if !cfg.Broadcast {
+		tx = cfg.TotalTxDelivered
+		what = "TX delivered"
+	}
+
+	for _, p := range procs {
+		fmt.Printf("****************************************************************************\n")
+		fmt.Printf("Monitored Process %d (%s)\n", p.pid, p.name)
+		fmt.Printf("    %9s %8s %10s %12s %12s %10s\n",
+			"Time Sec.", "CPU %", "RSS MB", "Read MB", "Written MB", "Ctx Sw.")
+		for _, iv := range p.intervals {
+			fmt.Printf("    %9.1f %8.1f %10.1f %12.3f %12.3f %10s\n",
+				iv.Time, 100*iv.CPU, float64(iv.RSS)/1e6,
+				float64(iv.Read)/1e6, float64(iv.Written)/1e6,
+				commafy(int64(iv.Ctx)))
+		}
+
+		var elapsed float64
+		if n := len(p.intervals); n != 0 {
+			elapsed = p.intervals[n-1].Time
+		}
+		cpu := p.last.cpu - p.first.cpu
+		written := p.last.written - p.first.written
+		fmt.Printf("    Total CPU              : %.3f seconds", cpu)
+		if elapsed != 0 {
+			fmt.Printf(" (%.1f%% average)", 100*cpu/elapsed)
+		}
+		fmt.Printf("\n")
+		fmt.Printf("    Max. RSS               : %.1f MB\n", float64(p.maxRSS)/1e6)
+		if p.first.io {
+			fmt.Printf("    Total Read             : %s bytes\n",
+				commafy(int64(p.last.read-p.first.read)))
+			fmt.Printf("    Total Written          : %s bytes\n", commafy(int64(written)))
+		}
+		fmt.Printf("    Context Switches       : %s\n",
+			commafy(int64(p.last.ctx-p.first.ctx)))
+		if tx != 0 {
+			fmt.Printf("    CPU per TX             : %.3f us (%s)\n",
+				1e6*cpu/float64(tx), what)
+			if p.first.io {
+				fmt.Printf("    Bytes Written per TX   : %.1f (%s)\n",
+					float64(written)/float64(tx), what)
+			}
+		}
+		if p.exited {
+			fmt.Printf("    Note                   : The process exited during the run\n")
+		}
+	}
+}
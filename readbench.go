@@ -0,0 +1,225 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net/rpc"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/orderer/common/bootstrap/provisional"
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/orderer"
+)
+
+// In a read benchmark (-readBenchmark) the deliver clients do not follow the
+// ledger, but issue many short seek requests for ranges of blocks of an
+// existing ledger, which exercises the random-read path of the orderer's
+// block store, as used by peers catching up. Nothing is broadcast.
+
+// Read benchmark modes
+const (
+	RandomReads = "random" // Ranges start anywhere in the ledger
+	ZipfReads   = "zipf"   // Ranges start near the newest block more often
+)
+
+// ReadStats are the statistics of the seeks of a read benchmark. The times
+// are from sending a seek request to the arrival of the first and the last
+// block of the range (ns).
+type ReadStats struct {
+	Seeks  uint64
+	Blocks uint64
+	Bytes  uint64 // Size of the marshaled blocks
+	First  Histogram
+	Last   Histogram
+}
+
+// merge merges the read statistics of another client.
+func (r *ReadStats) merge(o *ReadStats) {
+	r.Seeks += o.Seeks
+	r.Blocks += o.Blocks
+	r.Bytes += o.Bytes
+	r.First.Merge(&o.First)
+	r.Last.Merge(&o.Last)
+}
+
+// seekRanges chooses the ranges of blocks of a read benchmark, among the
+// blocks first through last of the ledger. Every range has -seekBlocks
+// blocks, unless the ledger is shorter. Random ranges start anywhere, but
+// Zipfian ranges start at the newest possible block with the highest
+// probability, since recent blocks are read most often.
+type seekRanges struct {
+	first  uint64
+	last   uint64
+	starts uint64 // # of possible starting blocks
+	random *rand.Rand
+	zipf   *rand.Zipf
+}
+
+// newSeekRanges creates the ranges of a client over a ledger.
+func newSeekRanges(cfg *Config, client *Client, first, last uint64) *seekRanges {
+	r := &seekRanges{
+		first:  first,
+		last:   last,
+		starts: 1,
+		random: rand.New(rand.NewSource(client.seed())),
+	}
+	if n := last - first + 1; n > uint64(cfg.SeekBlocks) {
+		r.starts = n - uint64(cfg.SeekBlocks) + 1
+	}
+	if (cfg.ReadBench == ZipfReads) && (r.starts > 1) {
+		r.zipf = rand.NewZipf(r.random, cfg.ZipfExponent, 1, r.starts-1)
+	}
+	return r
+}
+
+// next returns the next range.
+func (r *seekRanges) next(cfg *Config) (start, stop uint64) {
+	if r.zipf != nil {
+		start = r.first + r.starts - 1 - r.zipf.Uint64()
+	} else {
+		start = r.first + uint64(r.random.Int63n(int64(r.starts)))
+	}
+	stop = start + uint64(cfg.SeekBlocks) - 1
+	if stop > r.last {
+		stop = r.last
+	}
+	return
+}
+
+// readBenchmark runs the read benchmark of a deliver client on an open
+// deliver stream, then signals Done.
+func readBenchmark(cfg *Config, client *Client, stream orderer.AtomicBroadcast_DeliverClient,
+	rpcClient *rpc.Client, monitor *usageMonitor) {
+
+	server := cfg.Dservers[client.Server]
+	chainID := provisional.TestChainID
+
+	// Find the newest block of the ledger before synchronizing.
+
+	var newest uint64
+	err := seekBlocks(stream, server, chainID, seekNewest(), seekNewest(),
+		func(block *common.Block) error {
+			newest = block.Header.Number
+			return nil
+		})
+	if err != nil {
+		client.fail(rpcClient, "Deliver client %v: Finding the newest block failed: %s",
+			client, err)
+	}
+	if newest < cfg.StartBlock {
+		client.fail(rpcClient, "Deliver client %v: The ledger ends at block %d, "+
+			"before the start block %d", client, newest, cfg.StartBlock)
+	}
+	ranges := newSeekRanges(cfg, client, cfg.StartBlock, newest)
+
+	sample := syncClock(client, rpcClient)
+	var tStart time.Time
+	err = rpcClient.Call("Control.Start", client, &tStart)
+	if err != nil {
+		logger.Fatalf("Deliver client %v: RPC Control.Start failed: %s",
+			client, err)
+	}
+	clock := newClock(tStart, sample)
+
+	// Seek. Each range must be delivered in order and in full.
+
+	done := &DeliverClient{Client: *client, LastBlock: newest}
+	reads := &done.Reads
+	monitor.begin()
+	for seek := 0; seek < cfg.Seeks; seek++ {
+		start, stop := ranges.next(cfg)
+		expected := start
+		t0 := clock.since()
+		err = seekBlocks(stream, server, chainID,
+			seekSpecified(start), seekSpecified(stop),
+			func(block *common.Block) error {
+				now := clock.since()
+				if block.Header.Number != expected {
+					return fmt.Errorf("Block %d delivered for block %d",
+						block.Header.Number, expected)
+				}
+				if expected == start {
+					reads.First.Record(now - t0)
+				}
+				if expected == stop {
+					reads.Last.Record(now - t0)
+				}
+				expected++
+				reads.Blocks++
+				reads.Bytes += uint64(proto.Size(block))
+				return nil
+			})
+		if err != nil {
+			client.fail(rpcClient, "Deliver client %v: Seek %d of blocks %d-%d failed: %s",
+				client, seek, start, stop, err)
+		}
+		if expected != stop+1 {
+			client.fail(rpcClient, "Deliver client %v: Seek %d of blocks %d-%d "+
+				"ended at block %d", client, seek, start, stop, expected)
+		}
+		reads.Seeks++
+	}
+	tEnd := clock.since()
+	monitor.end()
+
+	clock.finish(syncClock(client, rpcClient))
+	done.Elapsed = float64(clock.correct(tEnd)) / 1e9
+	done.Clock = *clock
+	done.Usage = monitor.usage()
+
+	var ignore int
+	err = rpcClient.Call("Control.DeliverDone", done, &ignore)
+	if err != nil {
+		logger.Fatalf("Deliver client %v: RPC Control.DeliverDone failed: %s",
+			client, err)
+	}
+}
+
+// reportReads prints the statistics of a read benchmark. The seek rate is for
+// all deliver clients together.
+func (s *Stats) reportReads(cfg *Config) {
+
+	r := &s.Reads
+	fmt.Printf("****************************************************************************\n")
+	fmt.Printf("Read Benchmark\n")
+	fmt.Printf("    Mode                   : %s", cfg.ReadBench)
+	if cfg.ReadBench == ZipfReads {
+		fmt.Printf(" (exponent %g)", cfg.ZipfExponent)
+	}
+	fmt.Printf("\n")
+	fmt.Printf("    Blocks per Seek        : %d\n", cfg.SeekBlocks)
+	fmt.Printf("    Ledger                 : Blocks %d-%d\n", cfg.StartBlock, s.LastBlock)
+	fmt.Printf("    Duration               : %0.3f seconds\n", s.DdeliverAll)
+	fmt.Printf("    Seeks                  : %s\n", commafy(int64(r.Seeks)))
+	fmt.Printf("    Blocks Read            : %s\n", commafy(int64(r.Blocks)))
+	fmt.Printf("    Bytes Read             : %s\n", commafy(int64(r.Bytes)))
+	if s.DdeliverAll != 0 {
+		fmt.Printf("    Seeks per Second       : %s\n",
+			commafy(int64(float64(r.Seeks)/s.DdeliverAll)))
+		fmt.Printf("    Blocks per Second      : %s\n",
+			commafy(int64(float64(r.Blocks)/s.DdeliverAll)))
+		fmt.Printf("    Bytes per Second       : %s\n",
+			commafy(int64(float64(r.Bytes)/s.DdeliverAll)))
+	}
+	fmt.Printf("****************************************************************************\n")
+	printLatency("1st Block", &r.First)
+	printLatency("Last Block", &r.Last)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestSeekRanges(t *testing.T) {
+	tests := []struct {
+		mode        string
+		first, last uint64
+		seekBlocks  int
+	}{
+		{RandomReads, 0, 0, 1},
+		{RandomReads, 0, 99, 10},
+		{RandomReads, 5, 8, 10}, // Ledger shorter than a range
+		{RandomReads, 10, 19, 10},
+		{ZipfReads, 0, 999, 1},
+		{ZipfReads, 100, 199, 50},
+		{ZipfReads, 3, 3, 5},
+	}
+	for _, tt := range tests {
+		cfg := &Config{ReadBench: tt.mode, SeekBlocks: tt.seekBlocks, ZipfExponent: 1.1}
+		r := newSeekRanges(cfg, &Client{Client: 1}, tt.first, tt.last)
+		starts := make(map[uint64]int)
+		for i := 0; i < 10000; i++ {
+			start, stop := r.next(cfg)
+			if (start < tt.first) || (stop > tt.last) || (stop < start) {
+				t.Fatalf("%s [%d, %d]: range [%d, %d] is out of bounds",
+					tt.mode, tt.first, tt.last, start, stop)
+			}
+			want := uint64(tt.seekBlocks)
+			if n := tt.last - tt.first + 1; n < want {
+				want = n
+			}
+			if stop-start+1 != want {
+				t.Fatalf("%s [%d, %d]: range [%d, %d] has %d blocks, want %d",
+					tt.mode, tt.first, tt.last, start, stop, stop-start+1, want)
+			}
+			starts[start]++
+		}
+
+		// Every start is possible, and Zipfian ranges favor the newest. The
+		// oldest of many Zipfian starts are too rare to be seen.
+
+		if ((tt.mode == RandomReads) || (r.starts <= 100)) && (len(starts) != int(r.starts)) {
+			t.Errorf("%s [%d, %d]: %d starts seen, want %d",
+				tt.mode, tt.first, tt.last, len(starts), r.starts)
+		}
+		newest := tt.first + r.starts - 1
+		if (tt.mode == ZipfReads) && (r.starts > 1) && (starts[newest] <= starts[tt.first]) {
+			t.Errorf("%s [%d, %d]: newest start seen %d times, oldest %d times",
+				tt.mode, tt.first, tt.last, starts[newest], starts[tt.first])
+		}
+	}
+}
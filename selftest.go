@@ -0,0 +1,262 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/protos/common"
+	"github.com/hyperledger/fabric/protos/orderer"
+
+	"github.com/op/go-logging"
+
+	"google.golang.org/grpc"
+)
+
+// obx selftest runs the broadcast and deliver code of the clients against an
+// in-process sink in place of an orderer, to measure the largest rates a
+// single obx client process can generate and consume.
+
+// selftestBatchSize is the # of TX in each block synthesized by the sink.
+const selftestBatchSize = 100
+
+// selftestMaxBytes is the largest # of payload bytes the self-test may
+// broadcast, since the sink holds every TX until it is delivered.
+const selftestMaxBytes = 1 << 30
+
+// selftestAcks is the # of ACKs each broadcast stream to the sink can queue
+// before a send waits for the ACK thread of the stream.
+const selftestAcks = 1024
+
+// sink stands in for an orderer. Every TX broadcast is ACKed immediately, and
+// ordered into blocks of selftestBatchSize TX, which are then delivered. The
+// sink holds the marshaled envelope of every TX, so the size of a self-test
+// is limited to selftestMaxBytes of payload.
+type sink struct {
+	mutex   sync.Mutex
+	pending [][]byte
+	blocks  []*common.Block
+}
+
+// order adds a marshaled envelope to the pending block, cutting the block if
+// it is full.
+func (s *sink) order(envelope []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pending = append(s.pending, envelope)
+	if len(s.pending) == selftestBatchSize {
+		s.cut()
+	}
+}
+
+// cut cuts a block from the pending TX. The caller holds the mutex.
+func (s *sink) cut() {
+	if len(s.pending) == 0 {
+		return
+	}
+	s.blocks = append(s.blocks, &common.Block{
+		Header: &common.BlockHeader{Number: uint64(len(s.blocks))},
+		Data:   &common.BlockData{Data: s.pending},
+	})
+	s.pending = nil
+}
+
+// flush cuts a block from any TX still pending.
+func (s *sink) flush() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cut()
+}
+
+// sinkBroadcastStream is a broadcast stream to the sink. Envelopes are
+// marshaled on Send, as gRPC would, and the ACKs are queued for Recv.
+type sinkBroadcastStream struct {
+	grpc.ClientStream
+	sink *sink
+	acks chan *orderer.BroadcastResponse
+}
+
+func (s *sinkBroadcastStream) Send(envelope *common.Envelope) error {
+	marshaled, err := proto.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	s.sink.order(marshaled)
+	s.acks <- &orderer.BroadcastResponse{Status: common.Status_SUCCESS}
+	return nil
+}
+
+func (s *sinkBroadcastStream) Recv() (*orderer.BroadcastResponse, error) {
+	return <-s.acks, nil
+}
+
+func (s *sinkBroadcastStream) CloseSend() error {
+	return nil
+}
+
+// sinkDeliverStream is a deliver stream from the sink, which delivers every
+// block from the oldest. The seek request is ignored.
+type sinkDeliverStream struct {
+	grpc.ClientStream
+	sink *sink
+	next int
+}
+
+func (s *sinkDeliverStream) Send(*common.Envelope) error {
+	return nil
+}
+
+func (s *sinkDeliverStream) Recv() (*orderer.DeliverResponse, error) {
+	if s.next == len(s.sink.blocks) {
+		return nil, io.EOF
+	}
+	block := s.sink.blocks[s.next]
+	s.next++
+	return &orderer.DeliverResponse{
+		Type: &orderer.DeliverResponse_Block{Block: block},
+	}, nil
+}
+
+func (s *sinkDeliverStream) CloseSend() error {
+	return nil
+}
+
+// selftestRate prints the rates of one side of the self-test.
+func selftestRate(what string, tx, bytes uint64, elapsed float64) {
+	fmt.Printf("%s\n", what)
+	fmt.Printf("    TX             : %s\n", commafy(int64(tx)))
+	fmt.Printf("    Duration       : %.3f seconds\n", elapsed)
+	if elapsed != 0 {
+		fmt.Printf("    TPS            : %s\n",
+			commafy(int64(float64(tx)/elapsed)))
+		fmt.Printf("    Bytes/s        : %s\n",
+			commafy(int64(float64(bytes)/elapsed)))
+	}
+}
+
+// obx selftest is invoked as
+//
+//	obx selftest ... flags ...
+//
+// taking the same flags as a run. No servers are needed, and the workload of
+// the first broadcast client of the first server and channel is used. The
+// clients are built with all of the connections and streams of a client, but
+// only the -transactions, -payload, -payloadDistribution, -payloadContent,
+// -burst, -delay, phase and -maxInFlight flags affect the result.
+func selftest() {
+
+	logger = logging.MustGetLogger("selftest")
+	monitor := newUsageMonitor()
+
+	args := os.Args[2:]
+	servers := false
+	for _, arg := range args {
+		if strings.HasPrefix(strings.TrimLeft(arg, "-"), "bServers") {
+			servers = true
+		}
+	}
+	if !servers {
+		args = append(args, "-bServers=selftest")
+	}
+	cfg := newConfig(args)
+	workload := cfg.workload(0, 0)
+	if float64(workload.Transactions)*workload.PayloadDist.mean() > selftestMaxBytes {
+		bogus("transactions", fmt.Sprintf("small enough for the self-test "+
+			"to broadcast at most %s payload bytes", commafy(selftestMaxBytes)))
+	}
+
+	fail := func(format string, args ...interface{}) {
+		logger.Fatalf(format, args...)
+	}
+	s := &sink{}
+
+	// Broadcast into the sink, which stores the blocks.
+
+	client := &Client{Type: Broadcast}
+	var streams []orderer.AtomicBroadcast_BroadcastClient
+	for i := 0; i < cfg.ConnsPerClient*cfg.StreamsPerConn; i++ {
+		streams = append(streams, &sinkBroadcastStream{
+			sink: s,
+			acks: make(chan *orderer.BroadcastResponse, selftestAcks),
+		})
+	}
+	monitor.begin()
+	clock := localClock()
+	broadcast := &BroadcastClient{Client: *client}
+	broadcastStreams(cfg, client, workload, streams, clock, broadcast, nil, fail)
+	generated := float64(clock.since()) / 1e9
+	s.flush()
+
+	// Deliver the blocks back from the sink.
+
+	client = &Client{Type: Deliver}
+	var tx, delivered uint64
+	var blocks BlockStats
+	clock = localClock()
+	deliverBlocks(client, &sinkDeliverStream{sink: s},
+		clock, uint64(workload.Transactions),
+		func(r *txRecord) {
+			tx++
+			delivered += uint64(r.Size)
+		}, &blocks, fail)
+	consumed := float64(clock.since()) / 1e9
+	monitor.end()
+
+	// Report
+
+	fmt.Printf("****************************************************************************\n")
+	fmt.Printf("Self-Test\n")
+	fmt.Printf("    Payload        : %s\n", workload.PayloadDist)
+	fmt.Printf("    Burst          : %d\n", workload.Burst)
+	fmt.Printf("    Streams        : %d\n", len(streams))
+	fmt.Printf("    Blocks         : %s (%d TX each)\n",
+		commafy(int64(blocks.Blocks)), selftestBatchSize)
+	fmt.Printf("****************************************************************************\n")
+	selftestRate("Generate (Broadcast)", uint64(workload.Transactions),
+		broadcast.Bytes, generated)
+	if generated != 0 {
+		fmt.Printf("    Generation     : %.1f%% of the broadcast time\n",
+			100*float64(broadcast.Generation)/1e9/generated)
+	}
+	selftestRate("Consume (Deliver)", tx, delivered, consumed)
+	fmt.Printf("****************************************************************************\n")
+
+	// The self-test is CPU-bound by design, so saturation is not reported.
+
+	reportUsage("Self-Test Usage", []Usage{monitor.usage()}, false)
+	fmt.Printf("****************************************************************************\n")
+
+	// Each client process should have at least 2x headroom over the rate
+	// expected from the orderer. The sink runs in the same process as the
+	// clients, so the rates are conservative.
+
+	limit := generated
+	if consumed > limit {
+		limit = consumed
+	}
+	if limit != 0 {
+		fmt.Printf("With 2x headroom, a single broadcast or deliver client process supports\n")
+		fmt.Printf("an orderer rate of up to %s TPS per client\n",
+			commafy(int64(float64(workload.Transactions)/limit/2)))
+	}
+}
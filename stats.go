@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"sort"
 	"time"
 )
@@ -29,28 +30,85 @@ type Stats struct {
 	DdeliverAll   float64       // The duration of all deliver clients
 	Dbroadcast    [][][]float64 // The duration of each broadcast client
 	Ddeliver      [][][]float64 // The duration of each deliver client
+	Bclocks       [][][]Clock   // The clock estimate of each broadcast client
+	Dclocks       [][][]Clock   // The clock estimate of each deliver client
+	Busage        []Usage       // The resource usage of the broadcast clients
+	Dusage        []Usage       // The resource usage of the deliver clients
+	Procs         []*procStats  // The resource usage of monitored processes
 	Missing       uint64        // The composite # of missing TX
 	WrongChannel  uint64        // The composite # of TX on the wrong channel
+	LastBlock     uint64        // The last block delivered to any client
+	Blocks        BlockStats    // Blocks delivered to all deliver clients
+	Reads         ReadStats     // Seeks of all deliver clients in a read benchmark
+	CatchUps      []CatchUp     // The catch-up of each late joiner
+	Series        TimeSeries    // Time series of all clients
+	Regressed     bool          // Did the run regress against the baseline?
+
+	BytesBroadcast uint64       // Payload bytes actually broadcast
+	BytesDelivered uint64       // Payload bytes actually delivered
+	Bbytes         [][][]uint64 // Payload bytes broadcast by each client
+	Dbytes         [][][]uint64 // Payload bytes delivered to each client
+	Bsizes         Histogram    // Payload sizes broadcast
+	InFlight       Histogram    // TX in flight as each TX was broadcast
+	Blocked        uint64       // Broadcasts blocked on the in-flight window
+	GenRate        []float64    // Max. TX generation rate of each broadcast client
+	GenShare       []float64    // Fraction of each broadcast client's time generating TX
+	Dsizes         Histogram    // Payload sizes delivered
+	JoinerLatency  Histogram    // Latency of TX delivered to late joiners
+	DuringCatchUp  Histogram    // Latency of TX broadcast while late joiners caught up
+	OutsideCatchUp Histogram    // Latency of other TX, if there are late joiners
+	SlowLag        Histogram    // Latency of TX delivered to slow consumers
+	FastLag        Histogram    // Latency of TX delivered to other consumers
+	Bchannels      []PhaseStats // Measured broadcast statistics by channel
+	Dchannels      []PhaseStats // Measured deliver statistics by channel
+
+	Bmeasured [][][]PhaseStats // Measured statistics of each broadcast client
+	Dmeasured [][][]PhaseStats // Measured statistics of each deliver client
+
+	Bphases [NumPhases]PhaseStats // Broadcast statistics for each phase
+	Dphases [NumPhases]PhaseStats // Deliver statistics for each phase
 }
 
 // newStats initializes a Stats object.
 func newStats(cfg *Config) *Stats {
 
-	s := &Stats{}
+	s := &Stats{
+		Bchannels: make([]PhaseStats, cfg.Channels),
+		Dchannels: make([]PhaseStats, cfg.Channels),
+	}
 
 	s.Dbroadcast = make([][][]float64, cfg.NumBservers)
+	s.Bbytes = make([][][]uint64, cfg.NumBservers)
+	s.Bclocks = make([][][]Clock, cfg.NumBservers)
+	s.Bmeasured = make([][][]PhaseStats, cfg.NumBservers)
 	for server := 0; server < cfg.NumBservers; server++ {
 		s.Dbroadcast[server] = make([][]float64, cfg.Channels)
+		s.Bbytes[server] = make([][]uint64, cfg.Channels)
+		s.Bclocks[server] = make([][]Clock, cfg.Channels)
+		s.Bmeasured[server] = make([][]PhaseStats, cfg.Channels)
 		for channel := 0; channel < cfg.Channels; channel++ {
-			s.Dbroadcast[server][channel] = make([]float64, cfg.Bclients)
+			bClients := cfg.BclientsPerServer[server]
+			s.Dbroadcast[server][channel] = make([]float64, bClients)
+			s.Bbytes[server][channel] = make([]uint64, bClients)
+			s.Bclocks[server][channel] = make([]Clock, bClients)
+			s.Bmeasured[server][channel] = make([]PhaseStats, bClients)
 		}
 	}
 
 	s.Ddeliver = make([][][]float64, cfg.NumDservers)
+	s.Dbytes = make([][][]uint64, cfg.NumDservers)
+	s.Dclocks = make([][][]Clock, cfg.NumDservers)
+	s.Dmeasured = make([][][]PhaseStats, cfg.NumDservers)
 	for server := 0; server < cfg.NumDservers; server++ {
 		s.Ddeliver[server] = make([][]float64, cfg.Channels)
+		s.Dbytes[server] = make([][]uint64, cfg.Channels)
+		s.Dclocks[server] = make([][]Clock, cfg.Channels)
+		s.Dmeasured[server] = make([][]PhaseStats, cfg.Channels)
 		for channel := 0; channel < cfg.Channels; channel++ {
 			s.Ddeliver[server][channel] = make([]float64, cfg.Dclients)
+			s.Dbytes[server][channel] = make([]uint64, cfg.Dclients)
+			s.Dclocks[server][channel] = make([]Clock, cfg.Dclients)
+			s.Dmeasured[server][channel] = make([]PhaseStats, cfg.Dclients)
 		}
 	}
 
@@ -64,12 +122,21 @@ func (s *Stats) report(cfg *Config) {
 	fmt.Printf("* OBX Report                                                               *\n")
 	fmt.Printf("****************************************************************************\n")
 
-	// Report configuration and summary information
+	// Report configuration and summary information. If the run is phased,
+	// the headline statistics only cover the measured phases, and the whole
+	// run, including warm-up and cool-down, is labeled as such.
+
+	bMeasured := measuredStats(&s.Bphases)
+	dMeasured := measuredStats(&s.Dphases)
 
-	tpsb := float64(cfg.TotalTxBroadcast) / s.DbroadcastAll
-	tpsd := float64(cfg.TotalTxDelivered) / s.DdeliverAll
-	bpsb := float64(cfg.TotalBytesBroadcast) / s.DbroadcastAll
-	bpsd := float64(cfg.TotalBytesDelivered) / s.DdeliverAll
+	bDur, bTx, bBytes := s.DbroadcastAll, uint64(cfg.TotalTxBroadcast), s.BytesBroadcast
+	dDur, dTx, dBytes := s.DdeliverAll, uint64(cfg.TotalTxDelivered), s.BytesDelivered
+	title := ""
+	if cfg.phased() {
+		bDur, bTx, bBytes = bMeasured.duration(), bMeasured.Tx, bMeasured.Bytes
+		dDur, dTx, dBytes = dMeasured.duration(), dMeasured.Tx, dMeasured.Bytes
+		title = " (Measured: Ramp + Steady)"
+	}
 
 	fmt.Printf("Configuration\n")
 	fmt.Printf("    Broadcast Servers	   : %d: %v\n", cfg.NumBservers, cfg.Bservers)
@@ -78,31 +145,72 @@ func (s *Stats) report(cfg *Config) {
 	fmt.Printf("    Deliver Clients  	   : %d\n", cfg.Dclients)
 	fmt.Printf("    Channels         	   : %d\n", cfg.Channels)
 	fmt.Printf("    Transactions     	   : %d\n", cfg.Transactions)
-	fmt.Printf("    Payload          	   : %d\n", cfg.Payload)
+	fmt.Printf("    Payload          	   : %s\n", cfg.PayloadDist)
+	fmt.Printf("    Payload Content  	   : %s (gzip ratio %.2f)\n",
+		cfg.Content, measureCompression(cfg))
+	fmt.Printf("    Compression      	   : %s\n", cfg.Compression)
 	fmt.Printf("    Burst            	   : %d\n", cfg.Burst)
 	fmt.Printf("    Delay            	   : %s\n", cfg.Delay.String())
+	if cfg.MaxInFlight != 0 {
+		fmt.Printf("    Max. In-Flight   	   : %d\n", cfg.MaxInFlight)
+	}
+	if (cfg.ConnsPerClient != 1) || (cfg.StreamsPerConn != 1) {
+		fmt.Printf("    Connections      	   : %d x %d streams per broadcast client\n",
+			cfg.ConnsPerClient, cfg.StreamsPerConn)
+	}
+	for _, o := range cfg.WorkloadOverrides {
+		fmt.Printf("    Override         	   : %s\n", o)
+	}
+	if cfg.ChannelWeights != nil {
+		fmt.Printf("    Channel Skew     	   : %s\n", cfg.ChannelSkew)
+	}
 	fmt.Printf("    Window           	   : %d\n", cfg.Window)
 	fmt.Printf("    AckEvery         	   : %d\n", cfg.AckEvery)
 	fmt.Printf("    Broadcast?         	   : %v\n", cfg.Broadcast)
+	if cfg.Discover {
+		fmt.Printf("    Discovered?        	   : %v\n", cfg.Discover)
+	}
+	if cfg.phased() {
+		fmt.Printf("    Warm-up          	   : %s\n", cfg.Phases[Warmup])
+		fmt.Printf("    Ramp             	   : %s\n", cfg.Phases[Ramp])
+		fmt.Printf("    Cool-down        	   : %s\n", cfg.Phases[Cooldown])
+		fmt.Printf("    Ramp Delay       	   : %s\n", cfg.RampDelay.String())
+	}
 
 	if cfg.Broadcast {
 		fmt.Printf("****************************************************************************\n")
-		fmt.Printf("Broadcast Statistics\n")
-		fmt.Printf("    Broadcast Duration     : %0.3f seconds\n", s.DbroadcastAll)
-		fmt.Printf("    Tx Broadcast           : %s\n", commafy(int64(cfg.TotalTxBroadcast)))
-		fmt.Printf("    Tx Broadcast Rate      : %s TPS\n", commafy(int64(tpsb)))
-		fmt.Printf("    Payload Bytes Broadcast: %s\n", commafy(int64(cfg.TotalBytesBroadcast)))
-		fmt.Printf("    Payload Broadcast Rate : %s BPS\n", commafy(int64(bpsb)))
+		fmt.Printf("Broadcast Statistics%s\n", title)
+		fmt.Printf("    Broadcast Duration     : %0.3f seconds\n", bDur)
+		fmt.Printf("    Tx Broadcast           : %s\n", commafy(int64(bTx)))
+		fmt.Printf("    Tx Broadcast Rate      : %s TPS\n", commafy(int64(float64(bTx)/bDur)))
+		fmt.Printf("    Payload Bytes Broadcast: %s\n", commafy(int64(bBytes)))
+		fmt.Printf("    Payload Broadcast Rate : %s BPS\n", commafy(int64(float64(bBytes)/bDur)))
+		if cfg.phased() {
+			fmt.Printf("    Whole Run Duration     : %0.3f seconds\n", s.DbroadcastAll)
+			fmt.Printf("    Whole Run Tx Broadcast : %s\n", commafy(int64(cfg.TotalTxBroadcast)))
+			fmt.Printf("    Whole Run Tx Rate      : %s TPS\n",
+				commafy(int64(float64(cfg.TotalTxBroadcast)/s.DbroadcastAll)))
+		}
 	}
 
-	if cfg.Dclients != 0 {
+	if cfg.ReadBench != "" {
+		s.reportReads(cfg)
+	}
+
+	if (cfg.Dclients != 0) && (cfg.ReadBench == "") {
 		fmt.Printf("****************************************************************************\n")
-		fmt.Printf("Deliver Statistics\n")
-		fmt.Printf("    Deliver Duration       : %0.3f seconds\n", s.DdeliverAll)
-		fmt.Printf("    Tx Delivered           : %s\n", commafy(int64(cfg.TotalTxDelivered)))
-		fmt.Printf("    Tx Delivery Rate       : %s TPS\n", commafy(int64(tpsd)))
-		fmt.Printf("    Payload Bytes Delivered: %s\n", commafy(int64(cfg.TotalBytesDelivered)))
-		fmt.Printf("    Payload Delivery Rate  : %s BPS\n", commafy(int64(bpsd)))
+		fmt.Printf("Deliver Statistics%s\n", title)
+		fmt.Printf("    Deliver Duration       : %0.3f seconds\n", dDur)
+		fmt.Printf("    Tx Delivered           : %s\n", commafy(int64(dTx)))
+		fmt.Printf("    Tx Delivery Rate       : %s TPS\n", commafy(int64(float64(dTx)/dDur)))
+		fmt.Printf("    Payload Bytes Delivered: %s\n", commafy(int64(dBytes)))
+		fmt.Printf("    Payload Delivery Rate  : %s BPS\n", commafy(int64(float64(dBytes)/dDur)))
+		if cfg.phased() {
+			fmt.Printf("    Whole Run Duration     : %0.3f seconds\n", s.DdeliverAll)
+			fmt.Printf("    Whole Run Tx Delivered : %s\n", commafy(int64(cfg.TotalTxDelivered)))
+			fmt.Printf("    Whole Run Tx Rate      : %s TPS\n",
+				commafy(int64(float64(cfg.TotalTxDelivered)/s.DdeliverAll)))
+		}
 	}
 	// Report broadcast percentiles
 
@@ -117,10 +225,19 @@ func (s *Stats) report(cfg *Config) {
 		var index int
 		for server := 0; server < cfg.NumBservers; server++ {
 			for channel := 0; channel < cfg.Channels; channel++ {
-				for client := 0; client < cfg.Bclients; client++ {
+				tx := cfg.workload(server, channel).Transactions
+				for client := 0; client < cfg.BclientsPerServer[server]; client++ {
+					if cfg.phased() {
+						m := &s.Bmeasured[server][channel][client]
+						bDuration[index] = m.duration()
+						bTPS[index] = m.rate(m.Tx)
+						bBPS[index] = m.rate(m.Bytes)
+						index++
+						continue
+					}
 					bDuration[index] = s.Dbroadcast[server][channel][client]
-					bTPS[index] = float64(cfg.TxBroadcastPerClient) / bDuration[index]
-					bBPS[index] = float64(cfg.BytesBroadcastPerClient) / bDuration[index]
+					bTPS[index] = float64(tx) / bDuration[index]
+					bBPS[index] = float64(s.Bbytes[server][channel][client]) / bDuration[index]
 					index++
 				}
 			}
@@ -130,6 +247,9 @@ func (s *Stats) report(cfg *Config) {
 		tBest, tMedian, t90, t95, tWorst := percentiles(bTPS, -1)
 		bBest, bMedian, b90, b95, bWorst := percentiles(bBPS, -1)
 
+		if cfg.phased() {
+			fmt.Printf("Broadcast clients, measured phases only\n")
+		}
 		fmt.Printf("Broadcast Clients  :       Best     Median        90%%        95%%      Worst\n")
 		fmt.Printf("    Duration Sec.  : %10.3f %10.3f %10.3f %10.3f %10.3f\n",
 			dBest, dMedian, d90, d95, dWorst)
@@ -139,11 +259,34 @@ func (s *Stats) report(cfg *Config) {
 		fmt.Printf("    Bytes Per Sec. : %10s %10s %10s %10s %10s\n",
 			commafy(int64(bBest)), commafy(int64(bMedian)), commafy(int64(b90)),
 			commafy(int64(b95)), commafy(int64(bWorst)))
+
+		fmt.Printf("****************************************************************************\n")
+		reportInFlight(cfg, &s.InFlight, s.Blocked)
+
+		// The generation rate is the rate at which the clients could
+		// create TX if they did nothing else. If generating TX took much of
+		// the clients' time, obx may be limiting the measurement.
+
+		if len(s.GenRate) != 0 {
+			gBest, gMedian, g90, g95, gWorst := percentiles(s.GenRate, -1)
+			sBest, sMedian, s90, s95, sWorst := percentiles(s.GenShare, 1)
+			fmt.Printf("****************************************************************************\n")
+			fmt.Printf("TX Generation      :       Best     Median        90%%        95%%      Worst\n")
+			fmt.Printf("    Max. Tx/Sec.   : %10s %10s %10s %10s %10s\n",
+				commafy(int64(gBest)), commafy(int64(gMedian)), commafy(int64(g90)),
+				commafy(int64(g95)), commafy(int64(gWorst)))
+			fmt.Printf("    %% Client Time  : %10.1f %10.1f %10.1f %10.1f %10.1f\n",
+				100*sBest, 100*sMedian, 100*s90, 100*s95, 100*sWorst)
+			if sWorst >= 0.5 {
+				fmt.Printf("    WARNING        : Some clients spent half their time or more generating TX;\n")
+				fmt.Printf("                     The results may show client limits, not orderer limits\n")
+			}
+		}
 	}
 
 	// Report delivery percentiles
 
-	if cfg.Dclients != 0 {
+	if (cfg.Dclients != 0) && (cfg.ReadBench == "") {
 
 		fmt.Printf("****************************************************************************\n")
 
@@ -151,34 +294,199 @@ func (s *Stats) report(cfg *Config) {
 		dTPS := make([]float64, cfg.TotalDeliverClients)
 		dBPS := make([]float64, cfg.TotalDeliverClients)
 
+		// Late joiners are left out, since their durations and rates
+		// include the time before they joined, and they have no measured
+		// statistics, since their TX are left out of the phases.
+
 		var index int
 		for server := 0; server < cfg.NumDservers; server++ {
 			for channel := 0; channel < cfg.Channels; channel++ {
 				for client := 0; client < cfg.Dclients; client++ {
+					if _, late := cfg.joinIndex(&Client{Client: client}); late {
+						continue
+					}
+					if cfg.phased() {
+						m := &s.Dmeasured[server][channel][client]
+						dDuration[index] = m.duration()
+						dTPS[index] = m.rate(m.Tx)
+						dBPS[index] = m.rate(m.Bytes)
+						index++
+						continue
+					}
 					dDuration[index] = s.Ddeliver[server][channel][client]
-					dTPS[index] = float64(cfg.TxDeliveredPerClient) / dDuration[index]
-					dBPS[index] = float64(cfg.BytesDeliveredPerClient) / dDuration[index]
+					dTPS[index] = float64(cfg.TxDeliveredPerChannel[channel]) / dDuration[index]
+					dBPS[index] = float64(s.Dbytes[server][channel][client]) / dDuration[index]
 					index++
 				}
 			}
 		}
 
-		dBest, dMedian, d90, d95, dWorst := percentiles(dDuration, 1)
-		tBest, tMedian, t90, t95, tWorst := percentiles(dTPS, -1)
-		bBest, bMedian, b90, b95, bWorst := percentiles(dBPS, -1)
+		if index != 0 {
+			dBest, dMedian, d90, d95, dWorst := percentiles(dDuration[:index], 1)
+			tBest, tMedian, t90, t95, tWorst := percentiles(dTPS[:index], -1)
+			bBest, bMedian, b90, b95, bWorst := percentiles(dBPS[:index], -1)
 
-		fmt.Printf("Deliver Clients    :       Best     Median      90%%        95%%      Worst\n")
-		fmt.Printf("    Duration Sec.  : %10.3f %10.3f %10.3f %10.3f %10.3f\n",
-			dBest, dMedian, d90, d95, dWorst)
-		fmt.Printf("    Tx Per Sec.    : %10s %10s %10s %10s %10s\n",
-			commafy(int64(tBest)), commafy(int64(tMedian)), commafy(int64(t90)),
-			commafy(int64(t95)), commafy(int64(tWorst)))
-		fmt.Printf("    Bytes Per Sec. : %10s %10s %10s %10s %10s\n",
-			commafy(int64(bBest)), commafy(int64(bMedian)), commafy(int64(b90)),
-			commafy(int64(b95)), commafy(int64(bWorst)))
+			if cfg.phased() {
+				fmt.Printf("Deliver clients, measured phases only\n")
+			}
+			fmt.Printf("Deliver Clients    :       Best     Median      90%%        95%%      Worst\n")
+			fmt.Printf("    Duration Sec.  : %10.3f %10.3f %10.3f %10.3f %10.3f\n",
+				dBest, dMedian, d90, d95, dWorst)
+			fmt.Printf("    Tx Per Sec.    : %10s %10s %10s %10s %10s\n",
+				commafy(int64(tBest)), commafy(int64(tMedian)), commafy(int64(t90)),
+				commafy(int64(t95)), commafy(int64(tWorst)))
+			fmt.Printf("    Bytes Per Sec. : %10s %10s %10s %10s %10s\n",
+				commafy(int64(bBest)), commafy(int64(bMedian)), commafy(int64(b90)),
+				commafy(int64(b95)), commafy(int64(bWorst)))
+		}
+
+		var all PhaseStats
+		for p := range s.Dphases {
+			all.merge(&s.Dphases[p])
+		}
+		fmt.Printf("****************************************************************************\n")
+		if cfg.phased() {
+			printLatency("Measured", &dMeasured.Latency)
+			printLatency("Whole Run", &all.Latency)
+		} else {
+			printLatency("All TX", &all.Latency)
+		}
+
+		// Latencies are differences of broadcast and deliver timestamps, so
+		// their error is bounded by the sum of the clock errors.
+
+		b := summarizeClocks(s.Bclocks)
+		d := summarizeClocks(s.Dclocks)
+		offset := b.maxOffset
+		if d.maxOffset > offset {
+			offset = d.maxOffset
+		}
+		fmt.Printf("    Clock Error    : +/- %.6f seconds (Max. offset %.6f seconds, max. drift %.1f ppm)\n",
+			float64(b.maxError+d.maxError)/1e9, float64(offset)/1e9,
+			1e6*math.Max(b.maxDrift, d.maxDrift))
+	}
+
+	// Report the late joiners, and how their catch-up affected the others.
+
+	if cfg.LateJoiners != 0 {
+		s.reportCatchUps(cfg)
+	}
+
+	// Report the slow and fast consumers separately, to show whether slow
+	// consumers hold back the others.
+
+	if cfg.SlowConsumers != 0 {
+		s.reportSlowConsumers(cfg)
+	}
+
+	// Report the payload size distribution, as broadcast if possible.
+
+	sizes := &s.Bsizes
+	if !cfg.Broadcast {
+		sizes = &s.Dsizes
+	}
+	if sizes.N != 0 {
+		fmt.Printf("****************************************************************************\n")
+		fmt.Printf("Payload Sizes      :       Best     Median        90%%        95%%        99%%      Worst\n")
+		fmt.Printf("    Bytes          : %10s %10s %10s %10s %10s %10s\n",
+			commafy(int64(sizes.Quantile(0))), commafy(int64(sizes.Quantile(.5))),
+			commafy(int64(sizes.Quantile(.9))), commafy(int64(sizes.Quantile(.95))),
+			commafy(int64(sizes.Quantile(.99))), commafy(int64(sizes.Quantile(1))))
+		fmt.Printf("    Mean Bytes     : %10s\n", commafy(int64(sizes.Mean())))
+	}
+
+	// Report the resource usage of the clients, which shows whether the
+	// clients rather than the orderer limited the run.
+
+	if (len(s.Busage) != 0) || (len(s.Dusage) != 0) {
+		fmt.Printf("****************************************************************************\n")
+		reportUsage("Broadcast Clients", s.Busage, true)
+		reportUsage("Deliver Clients", s.Dusage, true)
+	}
+
+	// Report the monitored processes, which show the cost of each TX.
+
+	if len(s.Procs) != 0 {
+		reportProcs(cfg, s.Procs)
+	}
+
+	// Report the blocks, which show how the orderer cuts them.
+
+	if s.Blocks.Blocks != 0 {
+		s.Blocks.report()
 	}
 
+	// Report each channel if there are several, since channels may be
+	// skewed or have their own workloads.
+
+	if cfg.Channels > 1 {
+		s.reportChannels(cfg)
+	}
+
+	// Report the phases, and the measured statistics that exclude warm-up
+	// and cool-down.
+
+	if cfg.phased() {
+		for p := Phase(0); p < NumPhases; p++ {
+			s.reportPhase(cfg, "Phase "+p.String(), &s.Bphases[p], &s.Dphases[p])
+		}
+		s.reportPhase(cfg, "Measured (Ramp + Steady)", &bMeasured, &dMeasured)
+	}
+
+	fmt.Printf("****************************************************************************\n")
+}
+
+// reportChannels prints the measured broadcast and deliver statistics of
+// each channel. The delivery rates are for all deliver clients of a channel.
+func (s *Stats) reportChannels(cfg *Config) {
+
 	fmt.Printf("****************************************************************************\n")
+	fmt.Printf("Channel Statistics\n")
+	fmt.Printf("    Channel    Tx Bcast  Bcast TPS    Tx Dlvrd   Dlvr TPS  Lat. Med.   Lat. 95%%   Lat. 99%%\n")
+	for channel := 0; channel < cfg.Channels; channel++ {
+		b := &s.Bchannels[channel]
+		d := &s.Dchannels[channel]
+		fmt.Printf("    %7d %11s %10s %11s %10s %10.6f %10.6f %10.6f\n",
+			channel,
+			commafy(int64(b.Tx)), commafy(int64(b.rate(b.Tx))),
+			commafy(int64(d.Tx)), commafy(int64(d.rate(d.Tx))),
+			d.Latency.Seconds(.5), d.Latency.Seconds(.95),
+			d.Latency.Seconds(.99))
+	}
+}
+
+// reportPhase prints the broadcast and deliver statistics for a phase, or a
+// combination of phases.
+func (s *Stats) reportPhase(cfg *Config, title string, b, d *PhaseStats) {
+
+	fmt.Printf("****************************************************************************\n")
+	fmt.Printf("%s\n", title)
+
+	if cfg.Broadcast {
+		fmt.Printf("    Broadcast Window       : %0.3f - %0.3f seconds\n",
+			float64(b.Tfirst)/1e9, float64(b.Tlast)/1e9)
+		fmt.Printf("    Tx Broadcast           : %s\n", commafy(int64(b.Tx)))
+		fmt.Printf("    Tx Broadcast Rate      : %s TPS\n", commafy(int64(b.rate(b.Tx))))
+		fmt.Printf("    Payload Broadcast Rate : %s BPS\n", commafy(int64(b.rate(b.Bytes))))
+	}
+
+	if cfg.Dclients != 0 {
+		fmt.Printf("    Delivery Window        : %0.3f - %0.3f seconds\n",
+			float64(d.Tfirst)/1e9, float64(d.Tlast)/1e9)
+		fmt.Printf("    Tx Delivered           : %s\n", commafy(int64(d.Tx)))
+		fmt.Printf("    Tx Delivery Rate       : %s TPS\n", commafy(int64(d.rate(d.Tx))))
+		fmt.Printf("    Payload Delivery Rate  : %s BPS\n", commafy(int64(d.rate(d.Bytes))))
+		printLatency("", &d.Latency)
+	}
+}
+
+// printLatency prints a latency percentile table from a histogram of
+// nanosecond latencies.
+func printLatency(title string, h *Histogram) {
+	fmt.Printf("Latency %-10s :       Best     Median        90%%        95%%        99%%      Worst\n", title)
+	fmt.Printf("    Seconds        : %10.6f %10.6f %10.6f %10.6f %10.6f %10.6f\n",
+		h.Seconds(0), h.Seconds(.5), h.Seconds(.9), h.Seconds(.95),
+		h.Seconds(.99), h.Seconds(1))
 }
 
 // Compute best, median, 90th and 95th percentiles and worst case from a slice
@@ -234,3 +542,40 @@ func commafy(n int64) string {
 	}
 	return sign + s
 }
+
+// Summary holds the headline results of a run. If the run used phases the
+// results only cover the measured phases. Rates are per second, and the
+// latency histogram is in ns.
+type Summary struct {
+	BroadcastTPS float64
+	BroadcastBPS float64
+	DeliverTPS   float64
+	DeliverBPS   float64
+	Latency      Histogram
+}
+
+// summary computes the Summary of a run.
+func (s *Stats) summary(cfg *Config) *Summary {
+
+	sum := &Summary{}
+	b := measuredStats(&s.Bphases)
+	d := measuredStats(&s.Dphases)
+	sum.Latency = d.Latency
+
+	if cfg.phased() {
+		sum.BroadcastTPS = b.rate(b.Tx)
+		sum.BroadcastBPS = b.rate(b.Bytes)
+		sum.DeliverTPS = d.rate(d.Tx)
+		sum.DeliverBPS = d.rate(d.Bytes)
+		return sum
+	}
+	if cfg.Broadcast {
+		sum.BroadcastTPS = float64(cfg.TotalTxBroadcast) / s.DbroadcastAll
+		sum.BroadcastBPS = float64(s.BytesBroadcast) / s.DbroadcastAll
+	}
+	if cfg.Dclients != 0 {
+		sum.DeliverTPS = float64(cfg.TotalTxDelivered) / s.DdeliverAll
+		sum.DeliverBPS = float64(s.BytesDelivered) / s.DdeliverAll
+	}
+	return sum
+}
@@ -0,0 +1,191 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+)
+
+// The charts of HTML reports are drawn as inline SVG, so that reports are
+// self-contained and can be viewed offline.
+
+// Chart geometry, in pixels
+const (
+	svgWidth  = 720
+	svgHeight = 360
+	svgLeft   = 80
+	svgRight  = 20
+	svgTop    = 40
+	svgBottom = 50
+	svgTicks  = 5
+)
+
+var svgColors = []string{
+	"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b",
+}
+
+// svgSeries is a named series of points of a line chart.
+type svgSeries struct {
+	Name string
+	X, Y []float64
+}
+
+// svgAxes maps data coordinates to chart coordinates.
+type svgAxes struct {
+	xMin, xMax, yMin, yMax float64
+}
+
+func (a *svgAxes) x(v float64) float64 {
+	return svgLeft + (v-a.xMin)/(a.xMax-a.xMin)*(svgWidth-svgLeft-svgRight)
+}
+
+func (a *svgAxes) y(v float64) float64 {
+	return svgHeight - svgBottom -
+		(v-a.yMin)/(a.yMax-a.yMin)*(svgHeight-svgTop-svgBottom)
+}
+
+// newSvgAxes creates axes covering a range of data. The y axis always
+// includes 0, and empty ranges are widened.
+func newSvgAxes(xMin, xMax, yMin, yMax float64) *svgAxes {
+	if yMin > 0 {
+		yMin = 0
+	}
+	if xMax <= xMin {
+		xMax = xMin + 1
+	}
+	if yMax <= yMin {
+		yMax = yMin + 1
+	}
+	return &svgAxes{xMin, xMax, yMin, yMax}
+}
+
+// svgLabel formats an axis tick label.
+func svgLabel(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
+
+// frame draws the title, axes, tick labels and axis labels of a chart.
+func (a *svgAxes) frame(buf *bytes.Buffer, title, xLabel, yLabel string) {
+	esc := template.HTMLEscapeString
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="11">`,
+		svgWidth, svgHeight)
+	fmt.Fprintf(buf, `<text x="%d" y="20" text-anchor="middle" font-size="14">%s</text>`,
+		svgWidth/2, esc(title))
+	for i := 0; i <= svgTicks; i++ {
+		xv := a.xMin + float64(i)*(a.xMax-a.xMin)/svgTicks
+		yv := a.yMin + float64(i)*(a.yMax-a.yMin)/svgTicks
+		fmt.Fprintf(buf, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#ddd"/>`,
+			a.x(xv), svgTop, a.x(xv), svgHeight-svgBottom)
+		fmt.Fprintf(buf, `<text x="%.1f" y="%d" text-anchor="middle">%s</text>`,
+			a.x(xv), svgHeight-svgBottom+15, svgLabel(xv))
+		fmt.Fprintf(buf, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`,
+			svgLeft, a.y(yv), svgWidth-svgRight, a.y(yv))
+		fmt.Fprintf(buf, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`,
+			svgLeft-5, a.y(yv)+4, svgLabel(yv))
+	}
+	fmt.Fprintf(buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#000"/>`,
+		svgLeft, svgTop, svgWidth-svgLeft-svgRight, svgHeight-svgTop-svgBottom)
+	fmt.Fprintf(buf, `<text x="%d" y="%d" text-anchor="middle">%s</text>`,
+		(svgWidth+svgLeft-svgRight)/2, svgHeight-10, esc(xLabel))
+	fmt.Fprintf(buf, `<text x="15" y="%d" text-anchor="middle" transform="rotate(-90 15 %d)">%s</text>`,
+		(svgHeight+svgTop-svgBottom)/2, (svgHeight+svgTop-svgBottom)/2, esc(yLabel))
+}
+
+// svgLineChart draws a line chart of one or more series, with a legend if
+// there are several.
+func svgLineChart(title, xLabel, yLabel string, series []svgSeries) template.HTML {
+
+	xMin, yMin := math.Inf(1), math.Inf(1)
+	xMax, yMax := math.Inf(-1), math.Inf(-1)
+	for _, s := range series {
+		for i := range s.X {
+			xMin = math.Min(xMin, s.X[i])
+			xMax = math.Max(xMax, s.X[i])
+			yMin = math.Min(yMin, s.Y[i])
+			yMax = math.Max(yMax, s.Y[i])
+		}
+	}
+	if math.IsInf(xMin, 1) {
+		xMin, xMax, yMin, yMax = 0, 1, 0, 1
+	}
+	a := newSvgAxes(xMin, xMax, yMin, yMax)
+
+	var buf bytes.Buffer
+	a.frame(&buf, title, xLabel, yLabel)
+	for i, s := range series {
+		color := svgColors[i%len(svgColors)]
+		fmt.Fprintf(&buf, `<polyline fill="none" stroke="%s" stroke-width="1.5" points="`, color)
+		for j := range s.X {
+			fmt.Fprintf(&buf, "%.1f,%.1f ", a.x(s.X[j]), a.y(s.Y[j]))
+		}
+		fmt.Fprintf(&buf, `"/>`)
+		if len(series) > 1 {
+			fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`,
+				svgLeft+10, svgTop+10+15*i, color)
+			fmt.Fprintf(&buf, `<text x="%d" y="%d">%s</text>`,
+				svgLeft+25, svgTop+19+15*i, template.HTMLEscapeString(s.Name))
+		}
+	}
+	fmt.Fprintf(&buf, `</svg>`)
+	return template.HTML(buf.String())
+}
+
+// svgBarChart draws a histogram of values, with bins of equal width.
+func svgBarChart(title, xLabel, yLabel string, values []float64, bins int) template.HTML {
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	if len(values) == 0 {
+		lo, hi = 0, 1
+	}
+	if hi <= lo {
+		hi = lo + 1
+	}
+	width := (hi - lo) / float64(bins)
+	counts := make([]float64, bins)
+	var most float64
+	for _, v := range values {
+		b := int((v - lo) / width)
+		if b >= bins {
+			b = bins - 1
+		}
+		counts[b]++
+		most = math.Max(most, counts[b])
+	}
+	a := newSvgAxes(lo, hi, 0, most)
+
+	var buf bytes.Buffer
+	a.frame(&buf, title, xLabel, yLabel)
+	for b, n := range counts {
+		if n == 0 {
+			continue
+		}
+		x0 := a.x(lo + float64(b)*width)
+		x1 := a.x(lo + float64(b+1)*width)
+		fmt.Fprintf(&buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" stroke="#fff"/>`,
+			x0, a.y(n), x1-x0, a.y(0)-a.y(n), svgColors[0])
+	}
+	fmt.Fprintf(&buf, `</svg>`)
+	return template.HTML(buf.String())
+}
@@ -0,0 +1,212 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// sweepTerm is one term of a sweep specification: a flag and the values it
+// takes on.
+type sweepTerm struct {
+	flag   string
+	values []string
+}
+
+// sweepRun is a single combination of the swept values, and its results.
+type sweepRun struct {
+	values  []string
+	cfg     *Config
+	summary *Summary
+}
+
+// parseSweep parses a sweep specification of the form
+//
+//	flag=value,value,...;flag=value,...
+//
+// Flags that control the sweep itself, or the control process, can not be
+// swept.
+func parseSweep(spec string) (terms []sweepTerm) {
+	for _, term := range strings.Split(spec, ";") {
+		if term == "" {
+			continue
+		}
+		kv := strings.SplitN(term, "=", 2)
+		if (len(kv) != 2) || (kv[0] == "") || (kv[1] == "") {
+			bogus("sweep", "a list of flag=value,value,... terms separated by ';'")
+		}
+		name := strings.TrimPrefix(kv[0], "-")
+		switch name {
+		case "sweep", "sweepCSV", "controlAddress", "startBlock":
+			bogus("sweep", "free of the -"+name+" flag")
+		}
+		terms = append(terms, sweepTerm{
+			flag:   name,
+			values: strings.Split(kv[1], ","),
+		})
+	}
+	if len(terms) == 0 {
+		bogus("sweep", "a non-empty sweep specification")
+	}
+	return
+}
+
+// sweep runs the cartesian product of the swept values back-to-back, reusing
+// the control process, then prints a summary table and optionally writes it
+// as CSV. The configurations for every run are created (and validated)
+// before the first run starts. Runs after the first start delivery after the
+// last block of the previous run, so that every run only sees its own
+// transactions.
+func sweep(control *Control, base *Config) {
+
+	terms := parseSweep(base.Sweep)
+
+	var runs []*sweepRun
+	index := make([]int, len(terms))
+	for {
+		args := append([]string{}, os.Args[1:]...)
+		run := &sweepRun{}
+		for i, term := range terms {
+			value := term.values[index[i]]
+			run.values = append(run.values, value)
+			args = append(args, "-"+term.flag+"="+value)
+		}
+		run.cfg = newConfig(args)
+		runs = append(runs, run)
+
+		// Advance the odometer; The last term varies fastest.
+
+		i := len(terms) - 1
+		for ; i >= 0; i-- {
+			index[i]++
+			if index[i] < len(terms[i].values) {
+				break
+			}
+			index[i] = 0
+		}
+		if i < 0 {
+			break
+		}
+	}
+
+	startBlock := base.StartBlock
+	var regressed int
+	for i, run := range runs {
+		logger.Infof("Sweep run %d of %d: %s", i+1, len(runs),
+			sweepLabel(terms, run.values))
+		run.cfg.StartBlock = startBlock
+		run.cfg.LatencyPrefix = fmt.Sprintf("%s.run%d", run.cfg.LatencyPrefix, i+1)
+		if run.cfg.Timeseries != "" {
+			run.cfg.Timeseries = runFile(run.cfg.Timeseries, i+1)
+		}
+		if run.cfg.Results != "" {
+			run.cfg.Results = runFile(run.cfg.Results, i+1)
+		}
+		if run.cfg.Baseline != "" {
+			run.cfg.Baseline = runFile(run.cfg.Baseline, i+1)
+		}
+		stats := control.run(run.cfg)
+		if (stats.Missing != 0) || (stats.WrongChannel != 0) {
+			logger.Fatalf("Aborting sweep due to missing TX and/or channel errors")
+		}
+		if run.cfg.Broadcast && (run.cfg.Dclients != 0) {
+			startBlock = stats.LastBlock + 1
+		}
+		run.summary = stats.summary(run.cfg)
+		if stats.Regressed {
+			regressed++
+		}
+	}
+
+	reportSweep(terms, runs)
+
+	if base.SweepCSV != "" {
+		f, err := os.Create(base.SweepCSV)
+		if err != nil {
+			logger.Fatalf("Error creating sweep CSV file: %s", err)
+		}
+		defer f.Close()
+		writeSweepCSV(f, terms, runs)
+	}
+
+	if regressed != 0 {
+		logger.Fatalf("Performance regressed against the baseline in %d of %d runs",
+			regressed, len(runs))
+	}
+}
+
+// sweepLabel describes a combination of swept values.
+func sweepLabel(terms []sweepTerm, values []string) string {
+	var labels []string
+	for i, term := range terms {
+		labels = append(labels, term.flag+"="+values[i])
+	}
+	return strings.Join(labels, " ")
+}
+
+// reportSweep prints the consolidated sweep report.
+func reportSweep(terms []sweepTerm, runs []*sweepRun) {
+
+	fmt.Printf("****************************************************************************\n")
+	fmt.Printf("* OBX Sweep Report                                                         *\n")
+	fmt.Printf("****************************************************************************\n")
+
+	for _, term := range terms {
+		fmt.Printf("%12s ", term.flag)
+	}
+	fmt.Printf("%12s %14s %12s %14s %10s %10s %10s\n",
+		"Bcast TPS", "Bcast BPS", "Dlvr TPS", "Dlvr BPS",
+		"Lat. Med.", "Lat. 95%", "Lat. 99%")
+
+	for _, run := range runs {
+		for _, value := range run.values {
+			fmt.Printf("%12s ", value)
+		}
+		s := run.summary
+		fmt.Printf("%12s %14s %12s %14s %10.6f %10.6f %10.6f\n",
+			commafy(int64(s.BroadcastTPS)), commafy(int64(s.BroadcastBPS)),
+			commafy(int64(s.DeliverTPS)), commafy(int64(s.DeliverBPS)),
+			s.Latency.Seconds(.5), s.Latency.Seconds(.95),
+			s.Latency.Seconds(.99))
+	}
+
+	fmt.Printf("****************************************************************************\n")
+}
+
+// writeSweepCSV writes the sweep summary in CSV form. Latencies are in
+// seconds.
+func writeSweepCSV(w io.Writer, terms []sweepTerm, runs []*sweepRun) {
+	for _, term := range terms {
+		fmt.Fprintf(w, "%s,", term.flag)
+	}
+	fmt.Fprintf(w, "BroadcastTPS,BroadcastBPS,DeliverTPS,DeliverBPS,"+
+		"LatencyMin,LatencyMedian,Latency90,Latency95,Latency99,LatencyMax\n")
+	for _, run := range runs {
+		for _, value := range run.values {
+			fmt.Fprintf(w, "%s,", value)
+		}
+		s := run.summary
+		fmt.Fprintf(w, "%.3f,%.3f,%.3f,%.3f,%.9f,%.9f,%.9f,%.9f,%.9f,%.9f\n",
+			s.BroadcastTPS, s.BroadcastBPS, s.DeliverTPS, s.DeliverBPS,
+			s.Latency.Seconds(0), s.Latency.Seconds(.5),
+			s.Latency.Seconds(.9), s.Latency.Seconds(.95),
+			s.Latency.Seconds(.99), s.Latency.Seconds(1))
+	}
+}
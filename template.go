@@ -0,0 +1,97 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/protos/common"
+)
+
+// The protobuf key of the Data field (2) of a Payload, which has the
+// length-delimited wire type (2).
+const payloadDataKey = 2<<3 | 2
+
+// payloadTemplate marshals obx payloads without the protobuf library. Every
+// payload of a broadcast client has the same header, so the marshaled header
+// is computed once, and each payload is built in a reusable buffer as the
+// header, the key and length of the Data field, and the data itself. The
+// data is returned for the TxHeader and content to be written in place. The
+// data is always at the same offset in the buffer, and the header and length
+// are written just before it, so bytes of the data the client does not write
+// (e.g., zero content) are left unchanged, as in the data buffer of a client
+// that marshals with the protobuf library. The buffer can be reused as soon
+// as the envelope holding it is sent, since gRPC marshals messages before
+// Send returns.
+type payloadTemplate struct {
+	prefix []byte // The marshaled header and the Data key
+	buf    []byte // Reusable buffer
+}
+
+// newPayloadTemplate creates a template for payloads with a given header,
+// and data of at most max bytes. The template is checked against the
+// protobuf library for the shortest and longest data, and for data on each
+// side of the sizes where the length of the data takes another byte, up to
+// max. Nil is returned if the encodings differ, in which case the client
+// must marshal its payloads with the protobuf library. Empty data is not
+// checked, since protobuf omits it and obx payloads always include the
+// TxHeader.
+func newPayloadTemplate(header *common.Header, max int) *payloadTemplate {
+
+	prefix, err := proto.Marshal(&common.Payload{Header: header})
+	if err != nil {
+		return nil
+	}
+	prefix = append(prefix, payloadDataKey)
+	t := &payloadTemplate{
+		prefix: prefix,
+		buf:    make([]byte, len(prefix)+binary.MaxVarintLen64+max),
+	}
+
+	for _, size := range []int{1, 127, 128, 16383, 16384, max} {
+		if size > max {
+			break
+		}
+		marshaled, data := t.marshal(size)
+		for i := range data {
+			data[i] = byte(i)
+		}
+		expected, err := proto.Marshal(&common.Payload{Header: header, Data: data})
+		if (err != nil) || !bytes.Equal(marshaled, expected) {
+			return nil
+		}
+		for i := range data { // Leave zero content zero
+			data[i] = 0
+		}
+	}
+	return t
+}
+
+// marshal returns a marshaled payload with data of a given size, and the
+// data within it. Protobuf lengths are encoded as unsigned varints.
+func (t *payloadTemplate) marshal(size int) (marshaled, data []byte) {
+	var length [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(length[:], uint64(size))
+	offset := len(t.prefix) + binary.MaxVarintLen64 // Room for any length
+	start := offset - n - len(t.prefix)
+	copy(t.buf[start:], t.prefix)
+	copy(t.buf[offset-n:], length[:n])
+	return t.buf[start : offset+size], t.buf[offset : offset+size]
+}
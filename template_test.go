@@ -0,0 +1,139 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/protos/common"
+)
+
+func TestPayloadTemplateMarshal(t *testing.T) {
+	headers := []*common.Header{
+		{
+			ChainHeader:     &common.ChainHeader{ChainID: "testchainid"},
+			SignatureHeader: &common.SignatureHeader{},
+		},
+		{
+			ChainHeader: &common.ChainHeader{
+				Type: 3, ChainID: "a-much-longer-chain-id", TxID: "tx", Epoch: 1 << 40,
+			},
+			SignatureHeader: &common.SignatureHeader{Creator: []byte("me"), Nonce: []byte{1, 2}},
+		},
+		{},
+	}
+	sizes := []int{1, TxHeaderSize, 127, 128, 16383, 16384, 100000}
+	for i, header := range headers {
+		template := newPayloadTemplate(header, 100000)
+		if template == nil {
+			t.Fatalf("header %d: newPayloadTemplate() = nil", i)
+		}
+		for _, size := range sizes {
+			marshaled, data := template.marshal(size)
+			for j := range data {
+				data[j] = byte(j * 7)
+			}
+			expected, err := proto.Marshal(&common.Payload{Header: header, Data: data})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(marshaled, expected) {
+				t.Errorf("header %d, size %d: template and protobuf encodings differ", i, size)
+			}
+		}
+	}
+}
+
+func TestNewPayloadTemplate(t *testing.T) {
+	header := &common.Header{
+		ChainHeader:     &common.ChainHeader{ChainID: "testchainid"},
+		SignatureHeader: &common.SignatureHeader{},
+	}
+	for _, max := range []int{1, 127, 128, 200, 16383, 16384, 20000} {
+		template := newPayloadTemplate(header, max)
+		if template == nil {
+			t.Errorf("newPayloadTemplate(%d) = nil", max)
+			continue
+		}
+		marshaled, data := template.marshal(max)
+		for _, b := range data {
+			if b != 0 {
+				t.Errorf("max %d: the data of a new template is not zero", max)
+				break
+			}
+		}
+		expected, _ := proto.Marshal(&common.Payload{Header: header, Data: data})
+		if !bytes.Equal(marshaled, expected) {
+			t.Errorf("max %d: template and protobuf encodings differ", max)
+		}
+	}
+}
+
+// TestPayloadTemplateBroadcast checks that a broadcast client building its
+// payloads from a template sends the same payloads as one marshaling each
+// payload with the protobuf library, as clients did before templates.
+func TestPayloadTemplateBroadcast(t *testing.T) {
+	header := &common.Header{
+		ChainHeader:     &common.ChainHeader{ChainID: "testchainid"},
+		SignatureHeader: &common.SignatureHeader{},
+	}
+	for _, content := range []string{ZeroContent, RandomContent, TextContent, "ratio:2"} {
+		cfg := &Config{Content: parseContentMode(content)}
+		dist := parsePayloadDist("uniform:62,2000", 0)
+		client := &Client{Server: 1, Channel: 2, Client: 3}
+
+		// The protobuf library
+
+		var expected [][]byte
+		sizer := newPayloadSizer(&dist, client)
+		pool := newPayloadPool(&cfg.Content, dist.Max, client.seed())
+		data := make([]byte, dist.Max)
+		payload := &common.Payload{Header: header}
+		for tx := 0; tx < 100; tx++ {
+			payload.Data = data[:sizer.next()]
+			(&TxHeader{Sequence: uint32(tx), Tbroadcast: uint64(tx)}).Put(payload.Data)
+			pool.fill(payload.Data[TxHeaderSize:])
+			marshaled, err := proto.Marshal(payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected = append(expected, marshaled)
+		}
+
+		// The template
+
+		sizer = newPayloadSizer(&dist, client)
+		random := pool
+		pool = newPayloadPool(&cfg.Content, dist.Max, client.seed())
+		if pool != nil {
+			copy(pool.buf, random.buf) // Random content is not repeatable
+		}
+		template := newPayloadTemplate(header, dist.Max)
+		for tx := 0; tx < 100; tx++ {
+			marshaled, data := template.marshal(sizer.next())
+			(&TxHeader{Sequence: uint32(tx), Tbroadcast: uint64(tx)}).Put(data)
+			pool.fill(data[TxHeaderSize:])
+			if !bytes.Equal(marshaled, expected[tx]) {
+				t.Errorf("%s: TX %d differs from the protobuf encoding", content, tx)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TimeBucket holds the events of one interval of a run. Deliveries are
+// counted for every deliver client, so each TX is delivered once to each
+// deliver client of its channel.
+type TimeBucket struct {
+	Broadcast      uint64    // # of TX broadcast
+	BroadcastBytes uint64    // Payload bytes broadcast
+	Acked          uint64    // # of TX acknowledged
+	Delivered      uint64    // # of TX delivered
+	DeliveredBytes uint64    // Payload bytes delivered
+	Latency        Histogram // Broadcast-to-delivery latencies (ns)
+}
+
+// TimeSeries is a series of fixed-length intervals of a run, indexed by time
+// since the start of the run. Each client records its own events, and the
+// series are merged by the control process. A nil TimeSeries records
+// nothing, so clients only record events if a time series is requested.
+type TimeSeries struct {
+	Interval uint64 // Interval length (ns)
+	Buckets  []TimeBucket
+}
+
+// newTimeSeries returns a TimeSeries if one is configured, or nil.
+func newTimeSeries(cfg *Config) *TimeSeries {
+	if cfg.Timeseries == "" {
+		return nil
+	}
+	return &TimeSeries{Interval: uint64(cfg.SeriesInterval)}
+}
+
+// bucket returns the bucket of a timestamp, growing the series as needed.
+func (s *TimeSeries) bucket(t uint64) *TimeBucket {
+	b := int(t / s.Interval)
+	if b >= len(s.Buckets) {
+		s.Buckets = append(s.Buckets, make([]TimeBucket, b+1-len(s.Buckets))...)
+	}
+	return &s.Buckets[b]
+}
+
+// broadcast records a TX broadcast at time t.
+func (s *TimeSeries) broadcast(t, bytes uint64) {
+	if s == nil {
+		return
+	}
+	b := s.bucket(t)
+	b.Broadcast++
+	b.BroadcastBytes += bytes
+}
+
+// ack records a TX acknowledged at time t.
+func (s *TimeSeries) ack(t uint64) {
+	if s == nil {
+		return
+	}
+	s.bucket(t).Acked++
+}
+
+// deliver records a TX delivered at time t.
+func (s *TimeSeries) deliver(t, bytes, latency uint64) {
+	if s == nil {
+		return
+	}
+	b := s.bucket(t)
+	b.Delivered++
+	b.DeliveredBytes += bytes
+	b.Latency.Record(latency)
+}
+
+// merge adds another time series to this one.
+func (s *TimeSeries) merge(o *TimeSeries) {
+	if o == nil {
+		return
+	}
+	s.Interval = o.Interval
+	if len(o.Buckets) > len(s.Buckets) {
+		s.Buckets = append(s.Buckets, make([]TimeBucket, len(o.Buckets)-len(s.Buckets))...)
+	}
+	for i := range o.Buckets {
+		b := &s.Buckets[i]
+		ob := &o.Buckets[i]
+		b.Broadcast += ob.Broadcast
+		b.BroadcastBytes += ob.BroadcastBytes
+		b.Acked += ob.Acked
+		b.Delivered += ob.Delivered
+		b.DeliveredBytes += ob.DeliveredBytes
+		b.Latency.Merge(&ob.Latency)
+	}
+}
+
+// timeSeriesRow is a row of the time series output. Rates are per second
+// over the interval, and latencies are in seconds. InFlight is the # of TX
+// broadcast but not yet acknowledged, and Undelivered is the # of TX
+// broadcast but not yet delivered to every deliver client of their channel,
+// both at the end of the interval.
+type timeSeriesRow struct {
+	Time         float64 // Start of the interval (seconds)
+	BroadcastTPS float64
+	BroadcastBPS float64
+	AckTPS       float64
+	DeliverTPS   float64
+	DeliverBPS   float64
+	LatencyP50   float64
+	LatencyP99   float64
+	InFlight     int64
+	Undelivered  int64
+}
+
+// writeTimeSeries writes the time series of a run to a file, as JSON if the
+// file name ends in .json and as CSV otherwise.
+func writeTimeSeries(cfg *Config, s *TimeSeries) (err error) {
+
+	seconds := float64(s.Interval) / 1e9
+	dClientsPerChannel := int64(cfg.NumDservers) * int64(cfg.Dclients)
+	var broadcast, acked, delivered int64
+	rows := make([]timeSeriesRow, len(s.Buckets))
+
+	for i := range s.Buckets {
+		b := &s.Buckets[i]
+		broadcast += int64(b.Broadcast)
+		acked += int64(b.Acked)
+		delivered += int64(b.Delivered)
+		r := &rows[i]
+		r.Time = float64(i) * seconds
+		r.BroadcastTPS = float64(b.Broadcast) / seconds
+		r.BroadcastBPS = float64(b.BroadcastBytes) / seconds
+		r.AckTPS = float64(b.Acked) / seconds
+		r.DeliverTPS = float64(b.Delivered) / seconds
+		r.DeliverBPS = float64(b.DeliveredBytes) / seconds
+		r.LatencyP50 = b.Latency.Seconds(.5)
+		r.LatencyP99 = b.Latency.Seconds(.99)
+		if cfg.Broadcast {
+			r.InFlight = broadcast - acked
+			if dClientsPerChannel != 0 {
+				r.Undelivered = broadcast - delivered/dClientsPerChannel
+			}
+		}
+	}
+
+	f, err := os.Create(cfg.Timeseries)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if strings.HasSuffix(cfg.Timeseries, ".json") {
+		out, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(f, "%s\n", out)
+		return err
+	}
+
+	fmt.Fprintf(f, "Time,BroadcastTPS,BroadcastBPS,AckTPS,DeliverTPS,DeliverBPS,"+
+		"LatencyP50,LatencyP99,InFlight,Undelivered\n")
+	for _, r := range rows {
+		_, err = fmt.Fprintf(f, "%.3f,%.1f,%.1f,%.1f,%.1f,%.1f,%.9f,%.9f,%d,%d\n",
+			r.Time, r.BroadcastTPS, r.BroadcastBPS, r.AckTPS, r.DeliverTPS,
+			r.DeliverBPS, r.LatencyP50, r.LatencyP99, r.InFlight, r.Undelivered)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// runFile returns the name of the file for one run of a sweep, which is the
+// configured name with the run number inserted before the extension.
+func runFile(path string, run int) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + strconv.Itoa(run) + ext
+}
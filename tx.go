@@ -24,6 +24,7 @@ import (
 // objects are tagged with multiple timestamps for latency measurements. The
 // transaction blobs also contain other arbitrary payload data. The timestamps
 // in the headers are time.Duration (ns) relative to the common start time.
+// The header ends with a magic number that identifies obx transactions.
 type TxHeader struct {
 	Tbroadcast uint64
 	Tack       uint64
@@ -35,9 +36,44 @@ type TxHeader struct {
 	Server     uint16 // Broadcast server #
 	Channel    uint16 // Broadcast channel # (Redundant?)
 	Client     uint16 // Server/Channel client #
+	Magic      uint32 // Always TxMagic
 }
 
-const TxHeaderSize = 58 // bytes
+const TxHeaderSize = 62 // bytes
+
+const TxMagic = 0x6f627821 // "obx!"
+
+// LegacyTxHeaderSize is the size of the header written by earlier versions of
+// obx, which did not end the header with the magic number.
+const LegacyTxHeaderSize = 58 // bytes
+
+// isTx returns true if a transaction payload begins with an obx TxHeader,
+// either current or legacy.
+func isTx(buf []byte) bool {
+	return hasMagic(buf) || isLegacyTx(buf)
+}
+
+// hasMagic returns true if a payload begins with a current obx TxHeader.
+func hasMagic(buf []byte) bool {
+	return (len(buf) >= TxHeaderSize) &&
+		(binary.BigEndian.Uint32(buf[LegacyTxHeaderSize:]) == TxMagic)
+}
+
+// isLegacyTx returns true if a payload begins with a legacy obx TxHeader,
+// so that ledgers written by earlier versions of obx can still be read.
+// Legacy broadcast clients only set the broadcast timestamp, so the other
+// five timestamps are zero, which is most unlikely in any other message.
+func isLegacyTx(buf []byte) bool {
+	if (len(buf) < LegacyTxHeaderSize) || hasMagic(buf) {
+		return false
+	}
+	for _, b := range buf[8:48] {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
 
 // Put serializes a TxHeader into a byte buffer.
 func (t *TxHeader) Put(buf []byte) {
@@ -51,9 +87,11 @@ func (t *TxHeader) Put(buf []byte) {
 	binary.BigEndian.PutUint16(buf[52:], t.Server)
 	binary.BigEndian.PutUint16(buf[54:], t.Channel)
 	binary.BigEndian.PutUint16(buf[56:], t.Client)
+	binary.BigEndian.PutUint32(buf[58:], TxMagic)
 }
 
-// Get deserializes a TxHeader from a byte buffer.
+// Get deserializes a TxHeader from a byte buffer. The magic number of a
+// legacy header is 0.
 func (t *TxHeader) Get(buf []byte) {
 	t.Tbroadcast = binary.BigEndian.Uint64(buf[0:])
 	t.Tack = binary.BigEndian.Uint64(buf[8:])
@@ -65,4 +103,29 @@ func (t *TxHeader) Get(buf []byte) {
 	t.Server = binary.BigEndian.Uint16(buf[52:])
 	t.Channel = binary.BigEndian.Uint16(buf[54:])
 	t.Client = binary.BigEndian.Uint16(buf[56:])
+	t.Magic = 0
+	if hasMagic(buf) {
+		t.Magic = TxMagic
+	}
+}
+
+// origin identifies the broadcast client that created a transaction.
+type origin struct {
+	Server  uint16
+	Channel uint16
+	Client  uint16
+}
+
+// origin returns the origin of a transaction.
+func (t *TxHeader) origin() origin {
+	return origin{Server: t.Server, Channel: t.Channel, Client: t.Client}
+}
+
+// latency returns the broadcast-to-delivery latency of a delivered
+// transaction in ns.
+func (t *TxHeader) latency() uint64 {
+	if t.Tdelivered < t.Tbroadcast {
+		return 0
+	}
+	return t.Tdelivered - t.Tbroadcast
 }
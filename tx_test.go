@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestTxHeaderRoundTrip(t *testing.T) {
+	tests := []TxHeader{
+		{},
+		{Tbroadcast: 1, Sequence: 2, Server: 3, Channel: 4, Client: 5},
+		{
+			Tbroadcast: 1 << 60, Tack: 2, Tarrived: 3, Tordered: 4,
+			Treturned: 5, Tdelivered: 6, Sequence: 0xffffffff,
+			Server: 0xffff, Channel: 0xffff, Client: 0xffff,
+		},
+	}
+	for _, h := range tests {
+		buf := make([]byte, TxHeaderSize+10)
+		h.Put(buf)
+		if !isTx(buf) || !hasMagic(buf) || isLegacyTx(buf) {
+			t.Errorf("%+v: isTx %v, hasMagic %v, isLegacyTx %v",
+				h, isTx(buf), hasMagic(buf), isLegacyTx(buf))
+		}
+		var got TxHeader
+		got.Get(buf)
+		h.Magic = TxMagic
+		if got != h {
+			t.Errorf("Get() = %+v, want %+v", got, h)
+		}
+	}
+}
+
+func TestIsTx(t *testing.T) {
+	legacy := func(n int) []byte {
+		buf := make([]byte, n+TxHeaderSize)
+		(&TxHeader{Tbroadcast: 12345, Sequence: 6, Client: 7}).Put(buf[:TxHeaderSize])
+		for i := LegacyTxHeaderSize; i < TxHeaderSize; i++ {
+			buf[i] = 0xaa // Legacy payload data
+		}
+		return buf[:n]
+	}
+	other := make([]byte, 100)
+	for i := range other {
+		other[i] = byte(i)
+	}
+	tests := []struct {
+		name   string
+		buf    []byte
+		tx     bool
+		legacy bool
+	}{
+		{"empty", nil, false, false},
+		{"short", make([]byte, LegacyTxHeaderSize-1), false, false},
+		{"legacy", legacy(LegacyTxHeaderSize), true, true},
+		{"legacy with payload", legacy(100), true, true},
+		{"other", other, false, false},
+	}
+	for _, tt := range tests {
+		if got := isTx(tt.buf); got != tt.tx {
+			t.Errorf("%s: isTx() = %v, want %v", tt.name, got, tt.tx)
+		}
+		if got := isLegacyTx(tt.buf); got != tt.legacy {
+			t.Errorf("%s: isLegacyTx() = %v, want %v", tt.name, got, tt.legacy)
+		}
+	}
+
+	var h TxHeader
+	h.Get(legacy(100))
+	if (h.Magic != 0) || (h.Tbroadcast != 12345) || (h.Sequence != 6) || (h.Client != 7) {
+		t.Errorf("legacy Get() = %+v", h)
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// The interval at which client processes sample their goroutine counts
+const usageSampleInterval = 100 * time.Millisecond
+
+// A client is taken to be CPU-saturated if it used at least this fraction of
+// a CPU during the measured run. A broadcast client generates and sends its
+// TX on a single goroutine, with one more goroutine receiving the ACKs of
+// each stream, and a deliver client receives and checks its TX on a single
+// goroutine, so a client near one CPU is most likely limited by the
+// goroutine doing most of its work.
+const saturatedCPU = 0.9
+
+// Usage is the resource usage of a client process over its lifetime. The
+// measured run is the part of the lifetime spent broadcasting or delivering,
+// excluding the start barrier, clock handshakes and other waits.
+type Usage struct {
+	Elapsed    float64 // Lifetime (seconds)
+	User       float64 // User CPU time (seconds)
+	System     float64 // System CPU time (seconds)
+	Run        float64 // Duration of the measured run (seconds)
+	RunCPU     float64 // User + system CPU time of the measured run (seconds)
+	MaxRSS     uint64  // Maximum resident set size (bytes)
+	GCPause    float64 // Total GC pause time (seconds)
+	NumGC      uint32  // # of garbage collections
+	Goroutines int     // Largest # of goroutines sampled
+}
+
+// cpu returns the CPU utilization of a client in CPUs, over the measured run
+// if it was marked, otherwise over the lifetime of the client.
+func (u *Usage) cpu() float64 {
+	if u.Run != 0 {
+		return u.RunCPU / u.Run
+	}
+	if u.Elapsed == 0 {
+		return 0
+	}
+	return (u.User + u.System) / u.Elapsed
+}
+
+// usageMonitor tracks the resource usage of a client process, sampling the
+// goroutine count in the background.
+type usageMonitor struct {
+	start      time.Time
+	goroutines int32
+	stop       chan struct{}
+	runStart   time.Time // Start of the measured run
+	startCPU   float64   // CPU time at the start of the measured run
+	run        float64   // Duration of the measured run
+	runCPU     float64   // CPU time of the measured run
+}
+
+// cpuTime returns the user + system CPU time of the process in seconds.
+func cpuTime() float64 {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		logger.Warningf("getrusage failed: %s", err)
+		return 0
+	}
+	return float64(ru.Utime.Nano()+ru.Stime.Nano()) / 1e9
+}
+
+// begin marks the start of the measured run.
+func (m *usageMonitor) begin() {
+	m.runStart = time.Now()
+	m.startCPU = cpuTime()
+}
+
+// end marks the end of the measured run.
+func (m *usageMonitor) end() {
+	m.run = time.Since(m.runStart).Seconds()
+	m.runCPU = cpuTime() - m.startCPU
+}
+
+// newUsageMonitor starts monitoring the resource usage of the process.
+func newUsageMonitor() *usageMonitor {
+	m := &usageMonitor{
+		start:      time.Now(),
+		goroutines: int32(runtime.NumGoroutine()),
+		stop:       make(chan struct{}),
+	}
+	go func() {
+		ticker := time.NewTicker(usageSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.sample()
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+	return m
+}
+
+// sample records the goroutine count.
+func (m *usageMonitor) sample() {
+	n := int32(runtime.NumGoroutine())
+	if n > atomic.LoadInt32(&m.goroutines) {
+		atomic.StoreInt32(&m.goroutines, n)
+	}
+}
+
+// usage stops monitoring and returns the resource usage of the process.
+// Maximum RSS is reported by Linux in KB.
+func (m *usageMonitor) usage() (u Usage) {
+	m.sample()
+	close(m.stop)
+	u.Elapsed = time.Since(m.start).Seconds()
+	u.Run = m.run
+	u.RunCPU = m.runCPU
+	u.Goroutines = int(atomic.LoadInt32(&m.goroutines))
+
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		logger.Warningf("getrusage failed: %s", err)
+	} else {
+		u.User = float64(ru.Utime.Nano()) / 1e9
+		u.System = float64(ru.Stime.Nano()) / 1e9
+		u.MaxRSS = uint64(ru.Maxrss) * 1024
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	u.GCPause = float64(ms.PauseTotalNs) / 1e9
+	u.NumGC = ms.NumGC
+	return
+}
+
+// reportUsage prints the resource usage of a type of client, and warns if
+// any were CPU-saturated, unless saturation is expected.
+func reportUsage(title string, usage []Usage, saturation bool) {
+
+	if len(usage) == 0 {
+		return
+	}
+	cpu := make([]float64, len(usage))
+	rss := make([]float64, len(usage))
+	gc := make([]float64, len(usage))
+	var saturated, goroutines int
+	for i := range usage {
+		u := &usage[i]
+		cpu[i] = 100 * u.cpu()
+		rss[i] = float64(u.MaxRSS) / 1e6
+		gc[i] = u.GCPause
+		if u.cpu() >= saturatedCPU {
+			saturated++
+		}
+		if u.Goroutines > goroutines {
+			goroutines = u.Goroutines
+		}
+	}
+
+	cBest, cMedian, c90, c95, cWorst := percentiles(cpu, 1)
+	rBest, rMedian, r90, r95, rWorst := percentiles(rss, 1)
+	gBest, gMedian, g90, g95, gWorst := percentiles(gc, 1)
+
+	fmt.Printf("%-19s:       Best     Median        90%%        95%%      Worst\n", title)
+	fmt.Printf("    CPU %%          : %10.1f %10.1f %10.1f %10.1f %10.1f\n",
+		cBest, cMedian, c90, c95, cWorst)
+	fmt.Printf("    Max. RSS MB    : %10.1f %10.1f %10.1f %10.1f %10.1f\n",
+		rBest, rMedian, r90, r95, rWorst)
+	fmt.Printf("    GC Pause Sec.  : %10.3f %10.3f %10.3f %10.3f %10.3f\n",
+		gBest, gMedian, g90, g95, gWorst)
+	fmt.Printf("    Max. Goroutines: %10d\n", goroutines)
+	if saturation && (saturated != 0) {
+		fmt.Printf("    WARNING        : %d of %d clients were CPU-saturated (>= %.0f%% of a CPU);\n",
+			saturated, len(usage), 100*saturatedCPU)
+		fmt.Printf("                     The results may show client limits, not orderer limits\n")
+		logger.Warningf("%d of %d %s were CPU-saturated", saturated, len(usage), title)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// bitmap is a set of TX indices, at one bit per TX.
+type bitmap []uint64
+
+func newBitmap(n uint64) bitmap {
+	return make(bitmap, (n+63)/64)
+}
+
+func (b bitmap) set(i uint64) {
+	b[i/64] |= 1 << (i % 64)
+}
+
+func (b bitmap) isSet(i uint64) bool {
+	return (b[i/64] & (1 << (i % 64))) != 0
+}
+
+// verifier checks the TX delivered to a deliver client one at a time, and
+// aggregates their statistics as it goes. The TX are sorted into phases
+// here, tracking each broadcast client separately. The TX of a late joiner
+// are not sorted into phases, since their latency includes the time before
+// the joiner joined, but recorded in a histogram of their own. The only
+// state that grows with the # of TX is the bitmap of the TX seen, at one bit
+// per TX, and the block statistics are also aggregated as blocks are
+// delivered, so a deliver client can verify a long run in little memory.
+type verifier struct {
+	cfg       *Config
+	done      *DeliverClient
+	checked   bitmap
+	trackers  map[origin]*phaseTracker
+	latencies *latencyWriter // Nil unless latency files are requested
+	catchUps  []CatchUp      // Of the late joiners, if splitting latencies
+	late      bool           // Is the client a late joiner?
+}
+
+// newVerifier creates a verifier recording its results in done, and the
+// latencies in a latency writer, which may be nil.
+func newVerifier(cfg *Config, done *DeliverClient,
+	latencies *latencyWriter) *verifier {
+
+	return &verifier{
+		cfg:       cfg,
+		done:      done,
+		checked:   newBitmap(cfg.TxDeliveredPerChannel[done.Channel]),
+		trackers:  make(map[origin]*phaseTracker),
+		latencies: latencies,
+	}
+}
+
+// add verifies a TX, and records its statistics.
+func (v *verifier) add(t *txRecord) {
+
+	done := v.done
+	channel := done.Channel
+	if int(t.Channel) != channel {
+		done.WrongChannel++
+	}
+	if v.latencies != nil {
+		v.latencies.add(t)
+	}
+	x, ok := v.cfg.txIndex(&t.TxHeader, channel)
+	if !ok {
+		return // Can't have been broadcast; Shows up as missing
+	}
+	v.checked.set(x)
+	done.Bytes += uint64(t.Size)
+	done.Sizes.Record(uint64(t.Size))
+	if v.late {
+		done.JoinerLatency.Record(t.latency())
+		return
+	}
+	pt := v.trackers[t.origin()]
+	if pt == nil {
+		total := v.cfg.workload(int(t.Server), channel).Transactions
+		pt = newPhaseTracker(v.cfg, uint64(total), true, &done.Phases)
+		v.trackers[t.origin()] = pt
+	}
+	pt.add(t.Sequence, t.Tbroadcast, t.Tdelivered,
+		uint64(t.Size), t.latency())
+	done.Series.deliver(t.Tdelivered, uint64(t.Size), t.latency())
+	if v.catchUps != nil {
+		h := &done.OutsideCatchUp
+		for i := range v.catchUps {
+			if v.catchUps[i].contains(t.Tbroadcast) {
+				h = &done.DuringCatchUp
+				break
+			}
+		}
+		h.Record(t.latency())
+	}
+}
+
+// finish counts the expected TX that were not seen, and closes the phases.
+func (v *verifier) finish(expected uint64) {
+	for x := uint64(0); x < expected; x++ {
+		if !v.checked.isSet(x) {
+			v.done.Missing++
+		}
+	}
+	for _, pt := range v.trackers {
+		pt.finish()
+	}
+}
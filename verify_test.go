@@ -0,0 +1,118 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestBitmap(t *testing.T) {
+	tests := []struct {
+		n   uint64
+		set []uint64
+	}{
+		{1, []uint64{0}},
+		{64, []uint64{0, 63}},
+		{65, []uint64{1, 64}},
+		{1000, []uint64{0, 127, 128, 500, 999}},
+	}
+	for _, tt := range tests {
+		b := newBitmap(tt.n)
+		if len(b) != int((tt.n+63)/64) {
+			t.Errorf("newBitmap(%d) has %d words", tt.n, len(b))
+		}
+		want := make(map[uint64]bool)
+		for _, i := range tt.set {
+			b.set(i)
+			want[i] = true
+		}
+		for i := uint64(0); i < tt.n; i++ {
+			if b.isSet(i) != want[i] {
+				t.Errorf("n %d: isSet(%d) = %v, want %v", tt.n, i, b.isSet(i), want[i])
+			}
+		}
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	cfg := &Config{
+		NumBservers:  1,
+		Channels:     2,
+		Bclients:     2,
+		Transactions: 10,
+		Payload:      TxHeaderSize,
+	}
+	cfg.setWorkloads(FixedPayload, testOverrides("serverWorkload"), testOverrides("channelWorkload"))
+
+	tests := []struct {
+		name         string
+		tx           []TxHeader
+		late         bool
+		missing      uint64
+		wrongChannel uint64
+		latencies    uint64 // # of latencies in the phases
+	}{
+		{
+			name: "some missing",
+			tx: []TxHeader{
+				{Channel: 1, Client: 0, Sequence: 0},
+				{Channel: 1, Client: 1, Sequence: 9},
+				{Channel: 1, Client: 1, Sequence: 9}, // Duplicate
+			},
+			missing:   18,
+			latencies: 3,
+		},
+		{
+			name: "wrong channel and unknown",
+			tx: []TxHeader{
+				{Channel: 0, Client: 0, Sequence: 0},
+				{Channel: 1, Client: 2, Sequence: 0},
+				{Channel: 1, Client: 0, Sequence: 10},
+			},
+			missing:      19,
+			wrongChannel: 1,
+			latencies:    1,
+		},
+		{
+			name:    "late joiner",
+			tx:      []TxHeader{{Channel: 1, Client: 0, Sequence: 5}},
+			late:    true,
+			missing: 19,
+		},
+	}
+	for _, tt := range tests {
+		done := &DeliverClient{Client: Client{Channel: 1}}
+		v := newVerifier(cfg, done, nil)
+		v.late = tt.late
+		for i := range tt.tx {
+			tt.tx[i].Tbroadcast = 1000
+			tt.tx[i].Tdelivered = 3000
+			v.add(&txRecord{TxHeader: tt.tx[i], Size: TxHeaderSize})
+		}
+		v.finish(cfg.TxDeliveredPerChannel[1])
+		m := measuredStats(&done.Phases)
+		if (done.Missing != tt.missing) || (done.WrongChannel != tt.wrongChannel) ||
+			(m.Latency.N != tt.latencies) {
+			t.Errorf("%s: Missing %d, WrongChannel %d, %d latencies; want %d, %d, %d",
+				tt.name, done.Missing, done.WrongChannel, m.Latency.N,
+				tt.missing, tt.wrongChannel, tt.latencies)
+		}
+		if tt.late && ((done.JoinerLatency.N != 1) || (done.JoinerLatency.Max != 2000)) {
+			t.Errorf("%s: JoinerLatency %+v", tt.name, done.JoinerLatency)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Workload is the broadcast workload of each broadcast client of a broadcast
+// server on a channel. By default every server and channel gets the workload
+// given by the global flags, but this can be overridden per broadcast server
+// and per channel.
+type Workload struct {
+	Transactions int           // # of transactions per client
+	PayloadDist  PayloadDist   // Payload size distribution
+	Burst        int           // # of transactions in a burst
+	Delay        time.Duration // Delay between bursts
+}
+
+// estimateBytes estimates the payload bytes of a number of transactions. The
+// estimate is exact for fixed-size payloads; Otherwise the actual byte counts
+// are reported by the clients.
+func (w *Workload) estimateBytes(tx uint64) uint64 {
+	if w.PayloadDist.Kind == FixedPayload {
+		return tx * uint64(w.PayloadDist.Min)
+	}
+	return uint64(float64(tx) * w.PayloadDist.mean())
+}
+
+// override is a single workload override for a broadcast server or channel,
+// given on the command line as INDEX:KEY=VALUE.
+type override struct {
+	index int
+	key   string
+	value string
+}
+
+// overrideFlag is a flag.Value that collects the overrides given by a
+// repeated flag.
+type overrideFlag struct {
+	name      string
+	overrides []override
+	raw       []string
+}
+
+func (f *overrideFlag) String() string {
+	return strings.Join(f.raw, " ")
+}
+
+// Set parses and records an override. The value is only checked once the
+// override is applied.
+func (f *overrideFlag) Set(val string) error {
+	kv := strings.SplitN(val, "=", 2)
+	ik := strings.SplitN(kv[0], ":", 2)
+	if (len(kv) != 2) || (len(ik) != 2) {
+		bogus(f.name, "of the form INDEX:KEY=VALUE")
+	}
+	index, err := strconv.Atoi(ik[0])
+	if (err != nil) || (index < 0) {
+		bogus(f.name, "of the form INDEX:KEY=VALUE with a non-negative INDEX")
+	}
+	f.overrides = append(f.overrides, override{index, ik[1], kv[1]})
+	f.raw = append(f.raw, val)
+	return nil
+}
+
+// workloadSpec is a Workload under construction. Payload distributions are
+// parsed once all overrides have been applied, since they depend on the
+// payload size.
+type workloadSpec struct {
+	Workload
+	payload     int
+	payloadDist string
+}
+
+// apply applies an override to a workloadSpec. Setting the payload size
+// implies a fixed payload size distribution. The bClients override is handled
+// separately, and only applies to servers.
+func (w *workloadSpec) apply(flag string, o *override) {
+	var err error
+	var n int
+	switch o.key {
+	case "transactions":
+		n, err = strconv.Atoi(o.value)
+		requireUint32(flag, n)
+		w.Transactions = n
+	case "payload":
+		n, err = strconv.Atoi(o.value)
+		requirePosInt(flag, n)
+		w.payload = n
+		w.payloadDist = FixedPayload
+	case "payloadDist":
+		w.payloadDist = o.value
+	case "burst":
+		n, err = strconv.Atoi(o.value)
+		requirePosInt(flag, n)
+		w.Burst = n
+	case "delay":
+		w.Delay, err = time.ParseDuration(o.value)
+		requirePosDuration(flag, w.Delay)
+	case "bClients":
+		if flag != "serverWorkload" {
+			bogus(flag, "free of bClients overrides")
+		}
+	default:
+		bogus(flag, "one of transactions, payload, payloadDist, burst, delay "+
+			"or (for servers) bClients")
+	}
+	if err != nil {
+		bogus(flag, "a valid "+o.key+" override")
+	}
+}
+
+// setWorkloads computes the workload of every broadcast server and channel
+// from the global workload flags and the server and channel overrides.
+// Channel overrides take precedence over server overrides. Any channel skew
+// redistributes each server's transactions over the channels after the server
+// overrides are applied, but before the channel overrides. It also computes
+// the number of broadcast clients for each server, and the TX layout.
+func (c *Config) setWorkloads(payloadDist string, servers, channels *overrideFlag) {
+
+	for _, o := range servers.overrides {
+		if o.index >= c.NumBservers {
+			bogus("serverWorkload", "given for an existing broadcast server")
+		}
+	}
+	for _, o := range channels.overrides {
+		if o.index >= c.Channels {
+			bogus("channelWorkload", "given for an existing channel")
+		}
+	}
+	c.WorkloadOverrides = append(servers.raw, channels.raw...)
+
+	c.BclientsPerServer = make([]int, c.NumBservers)
+	c.Workloads = make([][]Workload, c.NumBservers)
+	for server := 0; server < c.NumBservers; server++ {
+
+		c.BclientsPerServer[server] = c.Bclients
+		for _, o := range servers.overrides {
+			if (o.index == server) && (o.key == "bClients") {
+				n, err := strconv.Atoi(o.value)
+				if err != nil {
+					bogus("serverWorkload", "a valid bClients override")
+				}
+				requireUint16("serverWorkload", n)
+				c.BclientsPerServer[server] = n
+			}
+		}
+
+		c.Workloads[server] = make([]Workload, c.Channels)
+		for channel := 0; channel < c.Channels; channel++ {
+			w := workloadSpec{
+				Workload: Workload{
+					Transactions: c.Transactions,
+					Burst:        c.Burst,
+					Delay:        c.Delay,
+				},
+				payload:     c.Payload,
+				payloadDist: payloadDist,
+			}
+			for i := range servers.overrides {
+				if servers.overrides[i].index == server {
+					w.apply("serverWorkload", &servers.overrides[i])
+				}
+			}
+			if c.ChannelWeights != nil {
+				w.Transactions = int(float64(w.Transactions)*
+					float64(c.Channels)*c.ChannelWeights[channel] + 0.5)
+			}
+			for i := range channels.overrides {
+				if channels.overrides[i].index == channel {
+					w.apply("channelWorkload", &channels.overrides[i])
+				}
+			}
+			if w.payload < TxHeaderSize {
+				w.payload = TxHeaderSize
+			}
+			w.PayloadDist = parsePayloadDist(w.payloadDist, w.payload)
+			c.Workloads[server][channel] = w.Workload
+		}
+	}
+	c.setLayout()
+}
+
+// setLayout computes the layout of the TX delivered on each channel from the
+// workloads. The TX delivered on a channel are indexed by broadcast server,
+// then client, then sequence number.
+func (c *Config) setLayout() {
+	c.TxBase = make([][]uint64, c.Channels)
+	c.TxDeliveredPerChannel = make([]uint64, c.Channels)
+	c.BytesDeliveredPerChannel = make([]uint64, c.Channels)
+	for channel := 0; channel < c.Channels; channel++ {
+		c.TxBase[channel] = make([]uint64, c.NumBservers)
+		var base, bytes uint64
+		for server := 0; server < c.NumBservers; server++ {
+			w := &c.Workloads[server][channel]
+			tx := uint64(c.BclientsPerServer[server]) * uint64(w.Transactions)
+			c.TxBase[channel][server] = base
+			base += tx
+			bytes += w.estimateBytes(tx)
+		}
+		c.TxDeliveredPerChannel[channel] = base
+		c.BytesDeliveredPerChannel[channel] = bytes
+	}
+}
+
+// Channel skew models
+const (
+	NoSkew      = "none"
+	ZipfSkew    = "zipf"
+	HotSkew     = "hot"
+	WeightsSkew = "weights"
+)
+
+// parseChannelSkew parses the -channelSkew flag and returns the fraction of
+// the transactions of each broadcast server that go to each channel, or nil
+// if there is no skew. The models are
+//
+//	zipf:S           Channel i gets a share proportional to 1/(i+1)^S
+//	hot:FRACTION     Channel 0 gets FRACTION, the others share the rest
+//	weights:W0,W1... Channel i gets a share proportional to Wi
+func parseChannelSkew(val string, channels int) []float64 {
+
+	why := "none, zipf:S, hot:FRACTION or weights:W0,W1,... " +
+		"with a weight for each channel"
+
+	kv := strings.SplitN(val, ":", 2)
+	if (kv[0] == NoSkew) && (len(kv) == 1) {
+		return nil
+	}
+	if len(kv) != 2 {
+		bogus("channelSkew", why)
+	}
+	var args []float64
+	for _, a := range strings.Split(kv[1], ",") {
+		f, err := strconv.ParseFloat(a, 64)
+		if (err != nil) || (f < 0) {
+			bogus("channelSkew", why)
+		}
+		args = append(args, f)
+	}
+
+	weights := make([]float64, channels)
+	switch {
+	case (kv[0] == ZipfSkew) && (len(args) == 1):
+		for i := range weights {
+			weights[i] = 1 / math.Pow(float64(i+1), args[0])
+		}
+	case (kv[0] == HotSkew) && (len(args) == 1) && (args[0] <= 1):
+		if channels == 1 {
+			weights[0] = 1
+			break
+		}
+		weights[0] = args[0]
+		for i := 1; i < channels; i++ {
+			weights[i] = (1 - args[0]) / float64(channels-1)
+		}
+	case (kv[0] == WeightsSkew) && (len(args) == channels):
+		copy(weights, args)
+	default:
+		bogus("channelSkew", why)
+	}
+
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+	if total <= 0 {
+		bogus("channelSkew", "a model with a positive total weight")
+	}
+	for i := range weights {
+		weights[i] /= total
+	}
+	return weights
+}
+
+// workload returns the workload of a broadcast server on a channel.
+func (c *Config) workload(server, channel int) *Workload {
+	return &c.Workloads[server][channel]
+}
+
+// txIndex returns the index of a transaction among the transactions
+// delivered on a channel, or false if the transaction can not have been
+// broadcast on the channel by this configuration.
+func (c *Config) txIndex(t *TxHeader, channel int) (uint64, bool) {
+	server := int(t.Server)
+	if (server >= c.NumBservers) ||
+		(int(t.Client) >= c.BclientsPerServer[server]) {
+		return 0, false
+	}
+	tx := uint64(c.Workloads[server][channel].Transactions)
+	if uint64(t.Sequence) >= tx {
+		return 0, false
+	}
+	return c.TxBase[channel][server] + uint64(t.Client)*tx +
+		uint64(t.Sequence), true
+}
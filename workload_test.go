@@ -0,0 +1,169 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+                 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// testOverrides returns an overrideFlag holding the given overrides.
+func testOverrides(name string, vals ...string) *overrideFlag {
+	f := &overrideFlag{name: name}
+	for _, val := range vals {
+		f.Set(val)
+	}
+	return f
+}
+
+func TestSetWorkloads(t *testing.T) {
+	cfg := &Config{
+		NumBservers:  2,
+		Channels:     2,
+		Bclients:     2,
+		Transactions: 100,
+		Payload:      1000,
+		Burst:        1,
+		Delay:        time.Millisecond,
+	}
+	cfg.setWorkloads(FixedPayload,
+		testOverrides("serverWorkload", "1:transactions=10", "1:bClients=3"),
+		testOverrides("channelWorkload", "1:payload=10", "1:burst=5"))
+
+	type want struct {
+		tx, payload, burst int
+	}
+	tests := []struct {
+		server, channel int
+		want            want
+	}{
+		{0, 0, want{100, 1000, 1}},
+		{0, 1, want{100, TxHeaderSize, 5}},
+		{1, 0, want{10, 1000, 1}},
+		{1, 1, want{10, TxHeaderSize, 5}},
+	}
+	for _, tt := range tests {
+		w := cfg.workload(tt.server, tt.channel)
+		got := want{w.Transactions, w.PayloadDist.Min, w.Burst}
+		if got != tt.want {
+			t.Errorf("workload(%d, %d) = %+v, want %+v", tt.server, tt.channel, got, tt.want)
+		}
+		if w.Delay != time.Millisecond {
+			t.Errorf("workload(%d, %d).Delay = %s", tt.server, tt.channel, w.Delay)
+		}
+	}
+	if (cfg.BclientsPerServer[0] != 2) || (cfg.BclientsPerServer[1] != 3) {
+		t.Errorf("BclientsPerServer = %v, want [2 3]", cfg.BclientsPerServer)
+	}
+	if (cfg.TxDeliveredPerChannel[0] != 230) || (cfg.TxDeliveredPerChannel[1] != 230) {
+		t.Errorf("TxDeliveredPerChannel = %v, want [230 230]", cfg.TxDeliveredPerChannel)
+	}
+	if b := cfg.BytesDeliveredPerChannel[1]; b != 230*TxHeaderSize {
+		t.Errorf("BytesDeliveredPerChannel[1] = %d, want %d", b, 230*TxHeaderSize)
+	}
+}
+
+func TestTxIndex(t *testing.T) {
+	cfg := &Config{
+		NumBservers:  2,
+		Channels:     1,
+		Bclients:     2,
+		Transactions: 100,
+		Payload:      TxHeaderSize,
+	}
+	cfg.setWorkloads(FixedPayload,
+		testOverrides("serverWorkload", "1:transactions=10", "1:bClients=3"),
+		testOverrides("channelWorkload"))
+
+	tests := []struct {
+		h     TxHeader
+		index uint64
+		ok    bool
+	}{
+		{TxHeader{Server: 0, Client: 0, Sequence: 0}, 0, true},
+		{TxHeader{Server: 0, Client: 1, Sequence: 99}, 199, true},
+		{TxHeader{Server: 0, Client: 2, Sequence: 0}, 0, false},
+		{TxHeader{Server: 0, Client: 0, Sequence: 100}, 0, false},
+		{TxHeader{Server: 1, Client: 0, Sequence: 0}, 200, true},
+		{TxHeader{Server: 1, Client: 2, Sequence: 9}, 229, true},
+		{TxHeader{Server: 1, Client: 2, Sequence: 10}, 0, false},
+		{TxHeader{Server: 2, Client: 0, Sequence: 0}, 0, false},
+	}
+	seen := make(map[uint64]bool)
+	for _, tt := range tests {
+		index, ok := cfg.txIndex(&tt.h, 0)
+		if (index != tt.index) || (ok != tt.ok) {
+			t.Errorf("txIndex(%+v) = %d, %v, want %d, %v", tt.h, index, ok, tt.index, tt.ok)
+		}
+		if ok {
+			if seen[index] {
+				t.Errorf("txIndex(%+v) = %d is not unique", tt.h, index)
+			}
+			seen[index] = true
+		}
+	}
+}
+
+func TestParseChannelSkew(t *testing.T) {
+	tests := []struct {
+		val      string
+		channels int
+		want     []float64
+	}{
+		{"none", 3, nil},
+		{"zipf:0", 4, []float64{0.25, 0.25, 0.25, 0.25}},
+		{"zipf:1", 3, []float64{6.0 / 11, 3.0 / 11, 2.0 / 11}},
+		{"hot:0.5", 3, []float64{0.5, 0.25, 0.25}},
+		{"hot:0.5", 1, []float64{1}},
+		{"weights:1,3", 2, []float64{0.25, 0.75}},
+		{"weights:0,2,0", 3, []float64{0, 1, 0}},
+	}
+	for _, tt := range tests {
+		got := parseChannelSkew(tt.val, tt.channels)
+		if len(got) != len(tt.want) {
+			t.Errorf("parseChannelSkew(%q, %d) = %v, want %v", tt.val, tt.channels, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if math.Abs(got[i]-tt.want[i]) > 1e-12 {
+				t.Errorf("parseChannelSkew(%q, %d) = %v, want %v", tt.val, tt.channels, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestChannelSkewWorkloads(t *testing.T) {
+	cfg := &Config{
+		NumBservers:    1,
+		Channels:       3,
+		Bclients:       1,
+		Transactions:   100,
+		Payload:        TxHeaderSize,
+		ChannelWeights: parseChannelSkew("weights:1,1,2", 3),
+	}
+	cfg.setWorkloads(FixedPayload,
+		testOverrides("serverWorkload"),
+		testOverrides("channelWorkload", "2:transactions=7"))
+	want := []int{75, 75, 7}
+	for channel, tx := range want {
+		if got := cfg.workload(0, channel).Transactions; got != tx {
+			t.Errorf("channel %d: %d TX, want %d", channel, got, tx)
+		}
+	}
+}